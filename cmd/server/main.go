@@ -6,6 +6,8 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -19,7 +21,14 @@ import (
 )
 
 const (
-	MAX_SSE_CLIENTS = 1
+	MAX_SSE_CLIENTS            = 10000 // default, overridable with SSE_MAX_CLIENTS and at runtime through the admin API
+	SSE_HEARTBEAT_INTERVAL     = 15 * time.Second
+	SSE_MIN_HEARTBEAT_INTERVAL = 5 * time.Second
+	SSE_MAX_HEARTBEAT_INTERVAL = 55 * time.Second
+	SSE_RETRY                  = 3 * time.Second
+	SSE_EVICTION_RETRY         = 1 * time.Second
+	SSE_EVICTION_RETRY_JITTER  = 10 * time.Second
+	SSE_DRAIN_TIMEOUT          = 5 * time.Second
 )
 
 var (
@@ -27,7 +36,9 @@ var (
 	metricController        *controller.MetricController
 	metricReadingController *controller.MetricReadingController
 	eventsController        *controller.EventsController
+	sseAdminController      *controller.SSEAdminController
 	eventStore              *repository.EventStoreInMemory
+	sseHub                  *sse.SSEHub
 	mockReadingsTicker      *metric_reading.MockReadingsTicker
 )
 
@@ -67,31 +78,93 @@ func setupRoutes(router *gin.Engine) {
 	metricsGroup := router.Group("/metrics")
 	metricReadingsGroup := metricsGroup.Group("/readings")
 	eventsGroup := router.Group("/events")
+	sseAdminGroup := router.Group("/admin/sse")
 
 	metricController.SetupRoutes(metricsGroup)
 	metricReadingController.SetupRoutes(metricReadingsGroup)
 	eventsController.SetupRoutes(eventsGroup)
+	sseAdminController.SetupRoutes(sseAdminGroup)
 }
 
 func setupDependencies() {
 	depsOnce.Do(func() {
 		inMemoryEventsTTL := 1 * time.Minute
 		eventStore = repository.NewEventStoreInMemory(inMemoryEventsTTL)
-		sse.InitializeSSEHub(eventStore, MAX_SSE_CLIENTS)
+		slowClientPolicy := sse.SlowClientDisconnect
+		if policyName := os.Getenv("SSE_SLOW_CLIENT_POLICY"); policyName != "" {
+			policy, err := sse.ParseSlowClientPolicy(policyName)
+			if err != nil {
+				log.Fatalf("invalid SSE_SLOW_CLIENT_POLICY: %s\n", err)
+			}
+			slowClientPolicy = policy
+		}
+
+		maxClients := envInt("SSE_MAX_CLIENTS", MAX_SSE_CLIENTS)
+
+		capacityPolicy := sse.CapacityEvictOldest
+		if policyName := os.Getenv("SSE_CAPACITY_POLICY"); policyName != "" {
+			policy, err := sse.ParseCapacityPolicy(policyName)
+			if err != nil {
+				log.Fatalf("invalid SSE_CAPACITY_POLICY: %s\n", err)
+			}
+			capacityPolicy = policy
+		}
+
+		quotas := sse.QuotaOptions{
+			PerIP:           envInt("SSE_MAX_STREAMS_PER_IP", 0),
+			PerPrincipal:    envInt("SSE_MAX_STREAMS_PER_PRINCIPAL", 0),
+			PrincipalLimits: envPrincipalLimits("SSE_PRINCIPAL_STREAM_LIMITS"),
+		}
+
+		// the hub outlives the signal context, it's drained and closed by gracefulShutdown
+		sseHub = sse.NewHub(context.Background(), sse.HubOptions{
+			EventStore:          eventStore,
+			MaxClients:          maxClients,
+			CapacityPolicy:      capacityPolicy,
+			Quotas:              quotas,
+			SlowClientPolicy:    slowClientPolicy,
+			EvictionRetry:       SSE_EVICTION_RETRY,
+			EvictionRetryJitter: SSE_EVICTION_RETRY_JITTER,
+			Shards:              envInt("SSE_SHARDS", 0),
+			Presence:            envBool("SSE_PRESENCE", true),
+		})
 
 		metricRepository := repository.NewMetricInMemoryRepository()
 		metricReadingRepository := repository.NewMetricReadingInMemoryRepository()
 
-		metricUseCase := use_case.NewMetricUseCase(metricRepository, metricReadingRepository)
+		metricUseCase := use_case.NewMetricUseCase(metricRepository, metricReadingRepository, sseHub)
 		metricController = controller.NewMetricController(metricUseCase)
 
-		metricReadingUseCase := use_case.NewMetricReadingUseCase(metricRepository, metricReadingRepository)
+		metricReadingUseCase := use_case.NewMetricReadingUseCase(metricRepository, metricReadingRepository, sseHub)
 		metricReadingController = controller.NewMetricReadingController(metricReadingUseCase)
 
-		eventsController = controller.NewEventsController()
+		eventsController = controller.NewEventsController(sseHub, sse.HandlerOptions{
+			Retry:                SSE_RETRY,
+			HeartbeatInterval:    SSE_HEARTBEAT_INTERVAL,
+			MinHeartbeatInterval: SSE_MIN_HEARTBEAT_INTERVAL,
+			MaxHeartbeatInterval: SSE_MAX_HEARTBEAT_INTERVAL,
+			Principal:            apiKeyPrincipal,
+			FlushInterval:        envDuration("SSE_FLUSH_INTERVAL", 0),
+			Compression:          envBool("SSE_COMPRESSION", true),
+			MaxReplay:            envInt("SSE_MAX_REPLAY", sse.DefaultMaxReplay),
+			Snapshot: func(r *http.Request) (any, error) {
+				return metricUseCase.GetAllMetrics(use_case.GetAllMetricsOptions{WithReadings: true})
+			},
+		})
+
+		sseAdminController = controller.NewSSEAdminController(sseHub)
 
 		if os.Getenv("MOCK_READINGS_TICKER") == "true" {
-			mockReadingsTicker = metric_reading.NewMockReadingsTicker(metricRepository, metricReadingRepository, 1*time.Second)
+			tickerInterval := 1 * time.Second
+			if rawInterval := os.Getenv("MOCK_READINGS_TICKER_INTERVAL"); rawInterval != "" {
+				interval, err := time.ParseDuration(rawInterval)
+				if err != nil || interval <= 0 {
+					log.Fatalf("invalid MOCK_READINGS_TICKER_INTERVAL: %q\n", rawInterval)
+				}
+				tickerInterval = interval
+			}
+
+			mockReadingsTicker = metric_reading.NewMockReadingsTicker(metricRepository, metricReadingRepository, sseHub, tickerInterval)
 			mockReadingsTicker.Start()
 		}
 	})
@@ -102,7 +175,7 @@ func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, Last-Event-ID")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, Last-Event-ID, X-API-Key, X-Resume-Token")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
@@ -114,6 +187,79 @@ func corsMiddleware() gin.HandlerFunc {
 	}
 }
 
+// Non-realistic authentication, for development only! The API key itself is used as the principal.
+// EventSource can't send custom headers, so the key may also come from the `api_key` query parameter.
+func apiKeyPrincipal(r *http.Request) string {
+	if apiKey := r.Header.Get("X-API-Key"); apiKey != "" {
+		return apiKey
+	}
+
+	return r.URL.Query().Get("api_key")
+}
+
+func envInt(name string, defaultValue int) int {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return defaultValue
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 0 {
+		log.Fatalf("invalid %s: %q\n", name, raw)
+	}
+
+	return value
+}
+
+func envBool(name string, defaultValue bool) bool {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return defaultValue
+	}
+
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		log.Fatalf("invalid %s: %q\n", name, raw)
+	}
+
+	return value
+}
+
+func envDuration(name string, defaultValue time.Duration) time.Duration {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return defaultValue
+	}
+
+	value, err := time.ParseDuration(raw)
+	if err != nil || value < 0 {
+		log.Fatalf("invalid %s: %q\n", name, raw)
+	}
+
+	return value
+}
+
+// envPrincipalLimits parses per-principal limits formatted as "key1=5,key2=10".
+func envPrincipalLimits(name string) map[string]int {
+	limits := make(map[string]int)
+
+	for _, pair := range strings.Split(os.Getenv(name), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		principal, rawLimit, ok := strings.Cut(pair, "=")
+		limit, err := strconv.Atoi(strings.TrimSpace(rawLimit))
+		if !ok || err != nil || limit < 0 {
+			log.Fatalf("invalid %s entry: %q\n", name, pair)
+		}
+
+		limits[strings.TrimSpace(principal)] = limit
+	}
+
+	return limits
+}
+
 func gracefulShutdown(srv *http.Server) {
 	eventStore.StopRetention()
 
@@ -121,6 +267,14 @@ func gracefulShutdown(srv *http.Server) {
 		mockReadingsTicker.Stop()
 	}
 
+	// tell the streaming clients first, open streams would otherwise hold srv.Shutdown until its timeout
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), SSE_DRAIN_TIMEOUT)
+	defer cancelDrain()
+
+	if err := sseHub.Drain(drainCtx); err != nil {
+		log.Printf("sse hub drain incomplete: %v\n", err)
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
 	defer cancel()
 
@@ -128,5 +282,7 @@ func gracefulShutdown(srv *http.Server) {
 		log.Printf("server forced to shutdown: %v\n", err)
 	}
 
+	sseHub.Close()
+
 	log.Println("server exiting")
 }
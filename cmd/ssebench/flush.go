@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Andrew-2609/go-sse-sample/pkg/sse"
+)
+
+type flushResult struct {
+	clients       int
+	flushInterval time.Duration
+	events        int
+	deliveries    int64
+	flushes       int64
+	elapsed       time.Duration
+}
+
+func benchFlush(clients []int, intervals []time.Duration, events int) {
+	log.SetOutput(io.Discard) // the handler logs every connection
+	defer log.SetOutput(os.Stderr)
+
+	const row = "%8v %15v %7v %11v %9v %16v %13v\n"
+	fmt.Printf(row, "clients", "flush interval", "events", "deliveries", "flushes", "events/flush", "deliveries/s")
+
+	for _, clientCount := range clients {
+		for _, interval := range intervals {
+			r := runFlush(clientCount, interval, events)
+
+			fmt.Printf(row,
+				r.clients, r.flushInterval, r.events, r.deliveries, r.flushes,
+				fmt.Sprintf("%.1f", float64(r.deliveries)/float64(max(r.flushes, 1))),
+				int(float64(r.deliveries)/r.elapsed.Seconds()),
+			)
+		}
+	}
+}
+
+// runFlush broadcasts events as fast as the clients read them over HTTP.
+// The block policy holds the hub back instead of dropping events, so the rate is the stream's throughput.
+func runFlush(clients int, flushInterval time.Duration, events int) flushResult {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hub := sse.NewHub(ctx, sse.HubOptions{
+		SlowClientPolicy:  sse.SlowClientBlock,
+		SlowClientTimeout: 10 * time.Second,
+	})
+
+	var flushes atomic.Int64
+	handler := hub.Handler(sse.HandlerOptions{FlushInterval: flushInterval})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(&countingFlusher{ResponseWriter: w, flushes: &flushes}, r)
+	}))
+
+	var connected, received sync.WaitGroup
+	connected.Add(clients)
+	received.Add(clients)
+
+	var deliveries atomic.Int64
+	responses := make([]*http.Response, 0, clients)
+
+	for range clients {
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			log.Fatalf("error connecting: %v", err)
+		}
+		responses = append(responses, resp)
+
+		go read(resp, events, &deliveries, &connected, &received)
+	}
+
+	connected.Wait()
+	flushes.Store(0)
+
+	start := time.Now()
+
+	for i := range events {
+		hub.Broadcast(sse.NewEvent("bench", map[string]any{"metric_id": "0192d6a4-7c1e-7000-8000-000000000000", "value": i}))
+	}
+
+	if !waitTimeout(&received, time.Minute) {
+		fmt.Fprintln(os.Stderr, "some clients didn't receive every event")
+	}
+	elapsed := time.Since(start)
+
+	// the hub goes first, so the streams are over when the server waits for them
+	hub.Close()
+	for _, resp := range responses {
+		resp.Body.Close()
+	}
+	srv.Close()
+
+	return flushResult{
+		clients:       clients,
+		flushInterval: flushInterval,
+		events:        events,
+		deliveries:    deliveries.Load(),
+		flushes:       flushes.Load(),
+		elapsed:       elapsed,
+	}
+}
+
+// read counts the events of a stream, the connected event aside.
+func read(resp *http.Response, events int, deliveries *atomic.Int64, connected, received *sync.WaitGroup) {
+	scanner := bufio.NewScanner(resp.Body)
+
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "event: connected") {
+			connected.Done()
+			break
+		}
+	}
+
+	count := 0
+	for count < events && scanner.Scan() {
+		if scanner.Text() == "event: bench" {
+			count++
+			deliveries.Add(1)
+		}
+	}
+
+	received.Done()
+}
+
+type countingFlusher struct {
+	http.ResponseWriter
+	flushes *atomic.Int64
+}
+
+func (c *countingFlusher) Flush() {
+	c.flushes.Add(1)
+	c.ResponseWriter.(http.Flusher).Flush()
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Andrew-2609/go-sse-sample/pkg/sse"
+)
+
+type latencyScenario struct {
+	clients  int
+	shards   int
+	events   int
+	interval time.Duration
+	buffer   int
+}
+
+type latencyResult struct {
+	latencyScenario
+	deliveries int
+	dropped    uint64
+	elapsed    time.Duration
+	p50        time.Duration
+	p99        time.Duration
+	max        time.Duration
+}
+
+func benchLatency(clients, shards []int, events int, interval time.Duration, buffer int) {
+	const row = "%8v %7v %7v %11v %8v %10v %10v %10v %13v\n"
+	fmt.Printf(row, "clients", "shards", "events", "deliveries", "dropped", "p50", "p99", "max", "deliveries/s")
+
+	for _, clientCount := range clients {
+		for _, shardCount := range shards {
+			r := runLatency(latencyScenario{
+				clients:  clientCount,
+				shards:   shardCount,
+				events:   events,
+				interval: interval,
+				buffer:   buffer,
+			})
+
+			fmt.Printf(row,
+				r.clients, r.shards, r.events, r.deliveries, r.dropped,
+				r.p50.Round(time.Microsecond), r.p99.Round(time.Microsecond), r.max.Round(time.Microsecond),
+				int(float64(r.deliveries)/r.elapsed.Seconds()),
+			)
+		}
+	}
+}
+
+func runLatency(s latencyScenario) latencyResult {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hub := sse.NewHub(ctx, sse.HubOptions{
+		Shards:           s.shards,
+		SlowClientPolicy: sse.SlowClientDropOldest,
+	})
+	defer hub.Close()
+
+	latencies := make([][]time.Duration, s.clients)
+
+	var received sync.WaitGroup
+	received.Add(s.clients)
+
+	for i := range s.clients {
+		client := sse.NewSSEClient(time.Now(), sse.ClientOptions{RemoteAddr: "127.0.0.1:" + strconv.Itoa(i), BufferSize: s.buffer})
+		if err := hub.Register(client); err != nil {
+			log.Fatalf("error registering client: %v", err)
+		}
+
+		latencies[i] = make([]time.Duration, 0, s.events)
+
+		go consume(client, s.events, &latencies[i], &received)
+	}
+
+	start := time.Now()
+
+	for range s.events {
+		event := sse.NewEvent("bench", map[string]any{"value": 42})
+		event.CreatedAt = time.Now()
+		hub.Broadcast(event)
+		time.Sleep(s.interval)
+	}
+
+	waitTimeout(&received, 10*time.Second)
+	elapsed := time.Since(start)
+
+	all := make([]time.Duration, 0, s.clients*s.events)
+	for _, l := range latencies {
+		all = append(all, l...)
+	}
+	slices.Sort(all)
+
+	return latencyResult{
+		latencyScenario: s,
+		deliveries:      len(all),
+		dropped:         hub.DroppedEvents(),
+		elapsed:         elapsed,
+		p50:             percentile(all, 0.50),
+		p99:             percentile(all, 0.99),
+		max:             percentile(all, 1),
+	}
+}
+
+// streamClient is the side of a client a stream handler works with.
+type streamClient interface {
+	Ready() <-chan struct{}
+	Pop() []sse.Event
+	Disconnect() <-chan struct{}
+}
+
+// consume takes events from the client's buffer like a stream handler would, recording their latency.
+func consume(client streamClient, events int, latencies *[]time.Duration, received *sync.WaitGroup) {
+	defer received.Done()
+
+	for count := 0; count < events; {
+		select {
+		case <-client.Ready():
+			now := time.Now()
+			for _, event := range client.Pop() {
+				*latencies = append(*latencies, now.Sub(event.CreatedAt))
+				count++
+			}
+		case <-client.Disconnect():
+			return
+		}
+	}
+}
+
+// waitTimeout gives up on clients that lost events, e.g. to the slow client policy.
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
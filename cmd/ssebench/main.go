@@ -0,0 +1,97 @@
+// ssebench benchmarks the hub with simulated clients.
+//
+// The latency mode measures the time from Broadcast to an event being taken from a client's buffer,
+// for every combination of client and shard counts:
+//
+//	go run ./cmd/ssebench -clients 1000,10000,50000 -shards 1,8
+//
+// The flush mode streams events over HTTP as fast as clients can read them,
+// comparing throughput and flushes with and without a flush window:
+//
+//	go run ./cmd/ssebench -mode flush -clients 100 -flush-intervals 0,20ms
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func main() {
+	mode := flag.String("mode", "latency", `"latency" or "flush"`)
+	clientCounts := flag.String("clients", "", "comma-separated numbers of simulated clients (default 1000,10000,50000 in latency mode, 100 in flush mode)")
+	shardCounts := flag.String("shards", "1,"+strconv.Itoa(runtime.GOMAXPROCS(0)), "comma-separated numbers of hub shards, latency mode")
+	events := flag.Int("events", 100, "events broadcast per scenario")
+	interval := flag.Duration("interval", 10*time.Millisecond, "delay between broadcasts, latency mode")
+	buffer := flag.Int("buffer", 64, "client buffer size, latency mode")
+	flushIntervals := flag.String("flush-intervals", "0,20ms", "comma-separated flush windows, flush mode")
+	flag.Parse()
+
+	switch *mode {
+	case "latency":
+		clients := mustParseInts("clients", withDefault(*clientCounts, "1000,10000,50000"))
+		shards := mustParseInts("shards", *shardCounts)
+		benchLatency(clients, shards, *events, *interval, *buffer)
+	case "flush":
+		clients := mustParseInts("clients", withDefault(*clientCounts, "100"))
+		intervals, err := parseDurations(*flushIntervals)
+		if err != nil {
+			log.Fatalf("invalid -flush-intervals: %v", err)
+		}
+		benchFlush(clients, intervals, *events)
+	default:
+		log.Fatalf("unknown mode %q", *mode)
+	}
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	i := int(float64(len(sorted))*p+0.5) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
+
+func withDefault(raw, defaultValue string) string {
+	if strings.TrimSpace(raw) == "" {
+		return defaultValue
+	}
+
+	return raw
+}
+
+func mustParseInts(name, raw string) []int {
+	values := make([]int, 0)
+
+	for _, field := range strings.Split(raw, ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || value <= 0 {
+			log.Fatalf("invalid -%s: %q must be a positive integer", name, field)
+		}
+		values = append(values, value)
+	}
+
+	return values
+}
+
+func parseDurations(raw string) ([]time.Duration, error) {
+	values := make([]time.Duration, 0)
+
+	for _, field := range strings.Split(raw, ",") {
+		value, err := time.ParseDuration(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		if value < 0 {
+			return nil, fmt.Errorf("%s must not be negative", value)
+		}
+		values = append(values, value)
+	}
+
+	return values, nil
+}
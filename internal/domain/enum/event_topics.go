@@ -0,0 +1,8 @@
+package enum
+
+import "github.com/google/uuid"
+
+// MetricTopic is the topic of every event related to the given metric.
+func MetricTopic(metricID uuid.UUID) string {
+	return "metric:" + metricID.String()
+}
@@ -16,11 +16,11 @@ type MetricReadingUseCase struct {
 	sseHub                  *sse.SSEHub
 }
 
-func NewMetricReadingUseCase(metricRepository entity.MetricRepository, metricReadingRepository entity.MetricReadingRepository) *MetricReadingUseCase {
+func NewMetricReadingUseCase(metricRepository entity.MetricRepository, metricReadingRepository entity.MetricReadingRepository, sseHub *sse.SSEHub) *MetricReadingUseCase {
 	return &MetricReadingUseCase{
 		metricRepository:        metricRepository,
 		metricReadingRepository: metricReadingRepository,
-		sseHub:                  sse.GetSSEHub(),
+		sseHub:                  sseHub,
 	}
 }
 
@@ -55,14 +55,27 @@ func (u *MetricReadingUseCase) CreateMetricReading(metricReadingDTO dto.CreateMe
 		return dto.CreateMetricReadingResponseDTO{}, err
 	}
 
-	metricReading, err := u.metricReadingRepository.CreateMetricReading(metricReadingEntity)
+	var response dto.CreateMetricReadingResponseDTO
+
+	// atomic with stream snapshots, see sse.SSEHub.BroadcastChange
+	err = u.sseHub.BroadcastChange(func() (sse.Event, error) {
+		metricReading, err := u.metricReadingRepository.CreateMetricReading(metricReadingEntity)
+		if err != nil {
+			return sse.Event{}, err
+		}
+
+		response = dto.NewCreateMetricReadingResponseDTO(metricReading)
+
+		event := sse.NewEvent(enum.EventTypeMetricReadingCreated, response).
+			WithTopic(enum.MetricTopic(metricReading.MetricID)).
+			WithConflationKey(metricReading.MetricID.String())
+
+		return event, nil
+	})
+
 	if err != nil {
 		return dto.CreateMetricReadingResponseDTO{}, err
 	}
 
-	response := dto.NewCreateMetricReadingResponseDTO(metricReading)
-
-	u.sseHub.Broadcast <- sse.NewEvent(enum.EventTypeMetricReadingCreated, response)
-
 	return response, nil
 }
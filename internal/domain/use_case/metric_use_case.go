@@ -16,11 +16,11 @@ type MetricUseCase struct {
 	sseHub                  *sse.SSEHub
 }
 
-func NewMetricUseCase(metricRepository entity.MetricRepository, metricReadingRepository entity.MetricReadingRepository) *MetricUseCase {
+func NewMetricUseCase(metricRepository entity.MetricRepository, metricReadingRepository entity.MetricReadingRepository, sseHub *sse.SSEHub) *MetricUseCase {
 	return &MetricUseCase{
 		metricRepository:        metricRepository,
 		metricReadingRepository: metricReadingRepository,
-		sseHub:                  sse.GetSSEHub(),
+		sseHub:                  sseHub,
 	}
 }
 
@@ -42,16 +42,24 @@ func (u *MetricUseCase) CreateMetric(metricDTO dto.CreateMetricRequestDTO) (dto.
 		return dto.CreateMetricResponseDTO{}, err
 	}
 
-	createdMetric, err := u.metricRepository.CreateMetric(metricEntity)
+	var response dto.CreateMetricResponseDTO
+
+	// atomic with stream snapshots, see sse.SSEHub.BroadcastChange
+	err = u.sseHub.BroadcastChange(func() (sse.Event, error) {
+		createdMetric, err := u.metricRepository.CreateMetric(metricEntity)
+		if err != nil {
+			return sse.Event{}, err
+		}
+
+		response = dto.NewCreateMetricResponseDTO(createdMetric)
+
+		return sse.NewEvent(enum.EventTypeMetricCreated, response).WithTopic(enum.MetricTopic(createdMetric.ID)), nil
+	})
 
 	if err != nil {
 		return dto.CreateMetricResponseDTO{}, err
 	}
 
-	response := dto.NewCreateMetricResponseDTO(createdMetric)
-
-	u.sseHub.Broadcast <- sse.NewEvent(enum.EventTypeMetricCreated, response)
-
 	return response, nil
 }
 
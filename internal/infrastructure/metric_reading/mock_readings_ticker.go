@@ -20,12 +20,12 @@ type MockReadingsTicker struct {
 	stop                    chan struct{}
 }
 
-func NewMockReadingsTicker(metricRepository entity.MetricRepository, metricReadingRepository entity.MetricReadingRepository, interval time.Duration) *MockReadingsTicker {
+func NewMockReadingsTicker(metricRepository entity.MetricRepository, metricReadingRepository entity.MetricReadingRepository, sseHub *sse.SSEHub, interval time.Duration) *MockReadingsTicker {
 	return &MockReadingsTicker{
 		metricRepository:        metricRepository,
 		metricReadingRepository: metricReadingRepository,
 		interval:                interval,
-		sseHub:                  sse.GetSSEHub(),
+		sseHub:                  sseHub,
 		stop:                    make(chan struct{}),
 	}
 }
@@ -84,16 +84,24 @@ func (t *MockReadingsTicker) Start() {
 						continue
 					}
 
-					_, err = t.metricReadingRepository.CreateMetricReading(newMetricReading)
+					err = t.sseHub.BroadcastChange(func() (sse.Event, error) {
+						if _, err := t.metricReadingRepository.CreateMetricReading(newMetricReading); err != nil {
+							return sse.Event{}, err
+						}
+
+						newMetricReadingResponse := dto.NewCreateMetricReadingResponseDTO(newMetricReading)
+
+						event := sse.NewEvent(enum.EventTypeMetricReadingCreated, newMetricReadingResponse).
+							WithTopic(enum.MetricTopic(metric.ID)).
+							WithConflationKey(metric.ID.String())
+
+						return event, nil
+					})
 
 					if err != nil {
 						log.Printf("error creating new metric reading for metric %s: %s", metric.ID, err)
 						continue
 					}
-
-					newMetricReadingResponse := dto.NewCreateMetricReadingResponseDTO(newMetricReading)
-
-					t.sseHub.Broadcast <- sse.NewEvent(enum.EventTypeMetricReadingCreated, newMetricReadingResponse)
 				}
 			case <-t.stop:
 				log.Println("stopping mock readings ticker")
@@ -1,143 +1,85 @@
 package controller
 
 import (
-	"encoding/json"
-	"fmt"
-	"io"
-	"log"
-	"math"
 	"net/http"
-	"time"
 
+	"github.com/Andrew-2609/go-sse-sample/internal/presentation/dto"
 	"github.com/Andrew-2609/go-sse-sample/pkg/sse"
 	"github.com/gin-gonic/gin"
 )
 
 type EventsController struct {
-	sseHub *sse.SSEHub
+	sseHub        *sse.SSEHub
+	streamHandler http.Handler
 }
 
-func NewEventsController() *EventsController {
+func NewEventsController(sseHub *sse.SSEHub, options sse.HandlerOptions) *EventsController {
 	return &EventsController{
-		sseHub: sse.GetSSEHub(),
+		sseHub:        sseHub,
+		streamHandler: sseHub.Handler(options),
 	}
 }
 
 func (c *EventsController) SetupRoutes(eventsGroup *gin.RouterGroup) {
 	eventsGroup.GET("/watch", c.WatchEvents)
+	eventsGroup.GET("/presence", c.GetPresence)
+	eventsGroup.POST("/clients/:id/groups", c.JoinGroup)
+	eventsGroup.DELETE("/clients/:id/groups/:group", c.LeaveGroup)
 }
 
 func (c *EventsController) WatchEvents(ctx *gin.Context) {
-	flusher, ok := ctx.Writer.(http.Flusher)
-	if !ok {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "flusher not supported"})
+	c.streamHandler.ServeHTTP(ctx.Writer, ctx.Request)
+}
+
+func (c *EventsController) GetPresence(ctx *gin.Context) {
+	presence, err := c.sseHub.Presence()
+	if err != nil {
+		ctx.JSON(hubErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	closeNotify := ctx.Writer.CloseNotify()
-
-	ctx.Writer.Header().Set("Content-Type", "text/event-stream")
-	ctx.Writer.Header().Set("Cache-Control", "no-cache")
-	ctx.Writer.Header().Set("Connection", "keep-alive")
-
-	connStartTime := time.Now().UTC()
-
-	client := sse.NewSSEClient(
-		make(chan sse.Event, 8),
-		connStartTime,
-	)
-
-	c.sseHub.Register <- client
-
-	log.Printf("new client connected at %s\n", connStartTime.Format(time.RFC3339))
-
-	defer func() {
-		c.sseHub.Unregister <- client
-	}()
+	ctx.JSON(http.StatusOK, presence)
+}
 
-	if err := c.printDataMessage(ctx.Writer, "connected"); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+func (c *EventsController) JoinGroup(ctx *gin.Context) {
+	var request dto.JoinGroupRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if lastEventID := ctx.GetHeader("Last-Event-ID"); lastEventID != "" {
-		events := c.sseHub.GetEventsAfterID(lastEventID)
-		c.sendEvents(ctx.Writer, events...)
+	if !c.authorizeClient(ctx) {
+		return
 	}
 
-	flusher.Flush()
-
-	// any `return` triggers defer -> unregister client
-	for {
-		select {
-		case <-client.Disconnect():
-			connDuration := time.Since(connStartTime)
-			log.Printf("client disconnected after %d seconds", int(math.Ceil(connDuration.Seconds())))
-
-			if err := c.printDataMessage(ctx.Writer, "disconnected"); err != nil {
-				return
-			}
-
-			flusher.Flush()
-			return
-		case event := <-client.CH():
-			if err := c.sendEvents(ctx.Writer, event); err != nil {
-				return
-			}
-
-			flusher.Flush()
-		case <-closeNotify:
-			connDuration := time.Since(connStartTime)
-			log.Printf("client disconnected after %d seconds", int(math.Ceil(connDuration.Seconds())))
-			return
-		}
+	if err := c.sseHub.JoinGroup(ctx.Param("id"), request.Group); err != nil {
+		ctx.JSON(hubErrorStatus(err), gin.H{"error": err.Error()})
+		return
 	}
-}
 
-func (c *EventsController) sendEvents(w io.Writer, events ...sse.Event) error {
-	printLines := func(lines ...string) error {
-		for _, line := range lines {
-			if _, err := fmt.Fprintf(w, "%s\n", line); err != nil {
-				return fmt.Errorf("error sending line: %w", err)
-			}
-		}
+	ctx.Status(http.StatusNoContent)
+}
 
-		return nil
+func (c *EventsController) LeaveGroup(ctx *gin.Context) {
+	if !c.authorizeClient(ctx) {
+		return
 	}
 
-	for _, event := range events {
-		if event.IsEmpty() {
-			continue
-		}
-
-		eventData, err := json.Marshal(event.Data)
-		if err != nil {
-			return fmt.Errorf("error marshalling event data: %w", err)
-		}
-
-		lines := []string{
-			fmt.Sprintf("id: %s", event.ID),
-			fmt.Sprintf("event: %s", event.Type),
-			fmt.Sprintf("data: %s", string(eventData)),
-		}
-
-		if err := printLines(lines...); err != nil {
-			return err
-		}
-
-		// end of event (CRITICAL)
-		if _, err := fmt.Fprintf(w, "\n"); err != nil {
-			return fmt.Errorf("error sending event end of line: %w", err)
-		}
+	if err := c.sseHub.LeaveGroup(ctx.Param("id"), ctx.Param("group")); err != nil {
+		ctx.JSON(hubErrorStatus(err), gin.H{"error": err.Error()})
+		return
 	}
 
-	return nil
+	ctx.Status(http.StatusNoContent)
 }
 
-func (c *EventsController) printDataMessage(w io.Writer, message string, args ...any) error {
-	if _, err := fmt.Fprintf(w, "data: %s\n\n", fmt.Sprintf(message, args...)); err != nil {
-		return fmt.Errorf("error sending message: %w", err)
+// authorizeClient checks the caller owns the client in the path with the resume token from its connected event:
+// client ids are public, e.g. in presence events.
+func (c *EventsController) authorizeClient(ctx *gin.Context) bool {
+	if !c.sseHub.ValidResumeToken(ctx.Param("id"), ctx.GetHeader("X-Resume-Token")) {
+		ctx.JSON(http.StatusForbidden, gin.H{"error": "invalid X-Resume-Token for the client"})
+		return false
 	}
 
-	return nil
+	return true
 }
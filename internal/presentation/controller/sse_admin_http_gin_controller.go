@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Andrew-2609/go-sse-sample/internal/presentation/dto"
+	"github.com/Andrew-2609/go-sse-sample/pkg/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// Non-authenticated admin routes, for development only!
+type SSEAdminController struct {
+	sseHub *sse.SSEHub
+}
+
+func NewSSEAdminController(sseHub *sse.SSEHub) *SSEAdminController {
+	return &SSEAdminController{
+		sseHub: sseHub,
+	}
+}
+
+func (c *SSEAdminController) SetupRoutes(adminSSEGroup *gin.RouterGroup) {
+	adminSSEGroup.GET("/clients", c.GetClients)
+	adminSSEGroup.DELETE("/clients/:id", c.DisconnectClient)
+	adminSSEGroup.POST("/events", c.PushEvent)
+	adminSSEGroup.GET("/stats", c.GetStats)
+	adminSSEGroup.PUT("/capacity", c.UpdateCapacity)
+}
+
+func (c *SSEAdminController) GetClients(ctx *gin.Context) {
+	clients, err := c.sseHub.Clients()
+	if err != nil {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, clients)
+}
+
+func (c *SSEAdminController) DisconnectClient(ctx *gin.Context) {
+	if err := c.sseHub.Disconnect(ctx.Param("id")); err != nil {
+		ctx.JSON(hubErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (c *SSEAdminController) PushEvent(ctx *gin.Context) {
+	var request dto.PushEventRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event := sse.NewEvent(sse.EventType(request.Event), request.Data).WithTopic(request.Topic)
+
+	// the hub discards broadcasts it can't encode, so bad events are caught here
+	if _, err := sse.MarshalEvent(event); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if request.ClientID != "" && request.Group != "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "client_id and group are mutually exclusive"})
+		return
+	}
+
+	var (
+		seq uint64
+		err error
+	)
+	switch {
+	case request.ClientID != "":
+		seq, err = c.sseHub.SendTo(request.ClientID, event)
+	case request.Group != "":
+		seq, err = c.sseHub.SendToGroup(request.Group, event)
+	default:
+		c.sseHub.Broadcast(event)
+	}
+	if err != nil {
+		ctx.JSON(hubErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, dto.PushEventResponseDTO{ID: event.ID, Seq: seq})
+}
+
+func (c *SSEAdminController) GetStats(ctx *gin.Context) {
+	stats, err := c.sseHub.Stats()
+	if err != nil {
+		ctx.JSON(hubErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, stats)
+}
+
+func (c *SSEAdminController) UpdateCapacity(ctx *gin.Context) {
+	var request dto.UpdateCapacityRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := c.sseHub.SetMaxClients(*request.MaxClients); err != nil {
+		ctx.JSON(hubErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.GetStats(ctx)
+}
+
+func hubErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, sse.ErrClientNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, sse.ErrHubClosed):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
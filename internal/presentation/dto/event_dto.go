@@ -0,0 +1,5 @@
+package dto
+
+type JoinGroupRequestDTO struct {
+	Group string `json:"group" binding:"required"`
+}
@@ -0,0 +1,19 @@
+package dto
+
+type PushEventRequestDTO struct {
+	Event    string `json:"event" binding:"required"`
+	Data     any    `json:"data" binding:"required"`
+	Topic    string `json:"topic,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+	Group    string `json:"group,omitempty"`
+}
+
+type PushEventResponseDTO struct {
+	ID string `json:"id"`
+	// Seq is the event's id on the wire, for events sent to a client or group. Broadcasts are published asynchronously.
+	Seq uint64 `json:"seq,omitempty"`
+}
+
+type UpdateCapacityRequestDTO struct {
+	MaxClients *int `json:"max_clients" binding:"required,min=0"`
+}
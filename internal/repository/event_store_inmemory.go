@@ -2,6 +2,8 @@ package repository
 
 import (
 	"log"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 
@@ -45,6 +47,46 @@ func (e *EventStoreInMemory) GetEventsAfterID(id string) []sse.Event {
 	return nil
 }
 
+func (e *EventStoreInMemory) QueryEvents(query sse.EventQuery) []sse.Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// events are stored in sequence order
+	start := sort.Search(len(e.events), func(i int) bool {
+		return e.events[i].Seq > query.AfterSeq
+	})
+
+	end := len(e.events)
+	if query.UntilSeq > 0 {
+		end = sort.Search(len(e.events), func(i int) bool {
+			return e.events[i].Seq > query.UntilSeq
+		})
+	}
+
+	if start >= end {
+		return []sse.Event{}
+	}
+
+	selected := e.events[start:end]
+
+	// but not in creation order: CreatedAt is set by publishers, before concurrent events get their sequence number
+	if !query.Since.IsZero() {
+		selected = slices.DeleteFunc(slices.Clone(selected), func(event sse.Event) bool {
+			return event.CreatedAt.Before(query.Since)
+		})
+	}
+
+	if query.Last > 0 && len(selected) > query.Last {
+		selected = selected[len(selected)-query.Last:]
+	}
+
+	if query.Limit > 0 && len(selected) > query.Limit {
+		selected = selected[:query.Limit]
+	}
+
+	return slices.Clone(selected)
+}
+
 func (e *EventStoreInMemory) startRetention(ttl time.Duration) {
 	ticker := time.NewTicker(ttl / 2)
 
@@ -1,25 +1,305 @@
 package sse
 
-import "time"
+import (
+	"log"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const DefaultClientBufferSize = 8
+
+type ClientOptions struct {
+	// ID identifies the client, a v7 UUID is generated when empty.
+	ID         string
+	RemoteAddr string
+	UserAgent  string
+	// Principal is the authenticated identity behind the connection, if any.
+	Principal string
+	// Priority is the client's priority class for CapacityEvictPriority, higher is kept longer.
+	Priority int
+	// Topics the client subscribes to. An event matches a topic by its type or its topic.
+	// A client without topics receives every event.
+	Topics []string
+	// Filter, when set, must match an event for it to be delivered to the client.
+	Filter *Filter
+	// Groups the client is a member of, for SSEHub.SendToGroup.
+	Groups []string
+	// BufferSize is the number of events queued for the client before the hub's slow client policy kicks in.
+	// Defaults to DefaultClientBufferSize.
+	BufferSize int
+}
 
 type sseClient struct {
-	ch             chan Event
+	id             string
+	remoteAddr     string
+	ip             string
+	userAgent      string
+	principal      string
+	priority       int
+	queue          *eventQueue
 	connectedAt    time.Time
+	topics         map[string]struct{}
+	filter         *Filter
+	groupsMu       sync.RWMutex // groups change mid-stream, while the client's handler replays events
+	groups         map[string]struct{}
+	shard          *hubShard // set by the hub on registration
 	disconnectChan chan struct{}
+	disconnectOnce sync.Once
+	registeredSeq  uint64   // hub position at registration, events up to it can only be replayed
+	farewell       farewell // set before closing disconnectChan
+	unregistered   chan struct{}
+	unregisterOnce sync.Once
+	eventsSent     atomic.Uint64
+	eventsDropped  atomic.Uint64
+	lastActivity   atomic.Int64 // unix nanoseconds of the last event sent, or of the connection
+}
+
+// ClientInfo is a snapshot of a connected client, for introspection.
+type ClientInfo struct {
+	ID            string    `json:"id"`
+	RemoteAddr    string    `json:"remote_addr"`
+	UserAgent     string    `json:"user_agent"`
+	Principal     string    `json:"principal,omitempty"`
+	Priority      int       `json:"priority"`
+	Topics        []string  `json:"topics"`
+	Filter        string    `json:"filter,omitempty"`
+	Groups        []string  `json:"groups"`
+	ConnectedAt   time.Time `json:"connected_at"`
+	EventsSent    uint64    `json:"events_sent"`
+	EventsDropped uint64    `json:"events_dropped"`
+	LastActivity  time.Time `json:"last_activity"`
+	QueueDepth    int       `json:"queue_depth"`
 }
 
-func NewSSEClient(ch chan Event, connectedAt time.Time) *sseClient {
-	return &sseClient{
-		ch:             ch,
+func NewSSEClient(connectedAt time.Time, opts ClientOptions) *sseClient {
+	topics := make(map[string]struct{}, len(opts.Topics))
+	for _, topic := range opts.Topics {
+		topics[topic] = struct{}{}
+	}
+
+	groups := make(map[string]struct{}, len(opts.Groups))
+	for _, group := range opts.Groups {
+		groups[group] = struct{}{}
+	}
+
+	bufferSize := opts.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = DefaultClientBufferSize
+	}
+
+	id := opts.ID
+	if id == "" {
+		id = uuid.New().String()
+
+		uuidV7, err := uuid.NewV7()
+		if err != nil {
+			log.Printf("error creating v7 UUID for client id: %v. A default UUID will be used instead.\n", err)
+		} else {
+			id = uuidV7.String()
+		}
+	}
+
+	c := &sseClient{
+		id:             id,
+		remoteAddr:     opts.RemoteAddr,
+		ip:             remoteIP(opts.RemoteAddr),
+		userAgent:      opts.UserAgent,
+		principal:      opts.Principal,
+		priority:       opts.Priority,
+		queue:          newEventQueue(bufferSize),
 		connectedAt:    connectedAt,
-		disconnectChan: make(chan struct{}, 1),
+		topics:         topics,
+		filter:         opts.Filter,
+		groups:         groups,
+		disconnectChan: make(chan struct{}),
+		unregistered:   make(chan struct{}),
+	}
+
+	c.lastActivity.Store(connectedAt.UnixNano())
+
+	return c
+}
+
+func (c *sseClient) ID() string {
+	return c.id
+}
+
+// Info returns a snapshot of the client's metadata and counters.
+func (c *sseClient) Info() ClientInfo {
+	topics := make([]string, 0, len(c.topics))
+	for topic := range c.topics {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	info := ClientInfo{
+		ID:            c.id,
+		RemoteAddr:    c.remoteAddr,
+		UserAgent:     c.userAgent,
+		Principal:     c.principal,
+		Priority:      c.priority,
+		Topics:        topics,
+		Groups:        c.groupList(),
+		ConnectedAt:   c.connectedAt,
+		EventsSent:    c.eventsSent.Load(),
+		EventsDropped: c.eventsDropped.Load(),
+		LastActivity:  time.Unix(0, c.lastActivity.Load()).UTC(),
+		QueueDepth:    c.queue.len(),
 	}
+
+	if c.filter != nil {
+		info.Filter = c.filter.String()
+	}
+
+	return info
+}
+
+// MarkSent records events written to the client's connection.
+func (c *sseClient) MarkSent(n int) {
+	c.eventsSent.Add(uint64(n))
+	c.lastActivity.Store(time.Now().UnixNano())
 }
 
-func (c *sseClient) CH() chan Event {
-	return c.ch
+// Ready is signalled when events are queued for the client, they must then be taken with Pop.
+func (c *sseClient) Ready() <-chan struct{} {
+	return c.queue.ready
+}
+
+// Pop takes every event queued for the client.
+func (c *sseClient) Pop() []Event {
+	events, _ := c.queue.pop()
+	return events
 }
 
 func (c *sseClient) Disconnect() <-chan struct{} {
 	return c.disconnectChan
 }
+
+// farewell is what a client is told when the hub lets go of it.
+type farewell struct {
+	retry time.Duration // reconnection hint, zero for none
+	event Event         // final event, none when empty
+}
+
+// disconnect closes the client's queue and signals Disconnect. Only the first call has an effect,
+// the hub and the client's shard may both give up on it.
+func (c *sseClient) disconnect(f farewell) {
+	c.disconnectOnce.Do(func() {
+		c.farewell = f
+		c.queue.close()
+		close(c.disconnectChan)
+	})
+}
+
+// markUnregistered tells a draining hub the client's stream is over.
+func (c *sseClient) markUnregistered() {
+	c.unregisterOnce.Do(func() {
+		close(c.unregistered)
+	})
+}
+
+func (c *sseClient) disconnected() bool {
+	select {
+	case <-c.disconnectChan:
+		return true
+	default:
+		return false
+	}
+}
+
+// RetryHint is the reconnection delay the hub suggests once the client has been disconnected.
+// Zero means no hint. It must only be read after Disconnect is signalled.
+func (c *sseClient) RetryHint() time.Duration {
+	return c.farewell.retry
+}
+
+// FinalEvent is the last event to send once the client has been disconnected, e.g. server_shutdown.
+// It's empty when there is none. It must only be read after Disconnect is signalled.
+func (c *sseClient) FinalEvent() Event {
+	return c.farewell.event
+}
+
+// Subscribed reports whether the event matches the client's topics and filter.
+// Events sent to a client or group only match their recipient or the group's members.
+func (c *sseClient) Subscribed(event Event) bool {
+	if event.Recipient != "" {
+		return event.Recipient == c.id
+	}
+
+	if event.Group != "" {
+		return c.inGroup(event.Group)
+	}
+
+	if !c.subscribedToTopic(event) {
+		return false
+	}
+
+	return c.filter == nil || c.filter.Match(event)
+}
+
+func (c *sseClient) inGroup(group string) bool {
+	c.groupsMu.RLock()
+	defer c.groupsMu.RUnlock()
+	_, ok := c.groups[group]
+	return ok
+}
+
+// groupList returns the client's groups, sorted.
+func (c *sseClient) groupList() []string {
+	c.groupsMu.RLock()
+	defer c.groupsMu.RUnlock()
+
+	groups := make([]string, 0, len(c.groups))
+	for group := range c.groups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	return groups
+}
+
+// setGroup adds the client to the group or removes it, returning false if it was already in that state.
+func (c *sseClient) setGroup(group string, member bool) bool {
+	c.groupsMu.Lock()
+	defer c.groupsMu.Unlock()
+
+	if _, ok := c.groups[group]; ok == member {
+		return false
+	}
+
+	if member {
+		c.groups[group] = struct{}{}
+	} else {
+		delete(c.groups, group)
+	}
+
+	return true
+}
+
+func (c *sseClient) subscribedToTopic(event Event) bool {
+	if len(c.topics) == 0 {
+		return !event.isSystem()
+	}
+
+	if _, ok := c.topics[string(event.Type)]; ok {
+		return true
+	}
+
+	_, ok := c.topics[event.Topic]
+	return ok && event.Topic != ""
+}
+
+// remoteIP strips the port from a "host:port" remote address.
+func remoteIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+
+	return host
+}
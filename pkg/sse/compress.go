@@ -0,0 +1,100 @@
+package sse
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// compressor is implemented by gzip.Writer and zlib.Writer.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// compressedWriter compresses a stream. Flushing it flushes the compressor, then the connection,
+// so every batch of events can be decoded as soon as it's received.
+type compressedWriter struct {
+	compressor compressor
+	flusher    http.Flusher
+	err        error
+}
+
+// newCompressedWriter wraps w with the given content encoding, "gzip" or "deflate".
+func newCompressedWriter(w io.Writer, flusher http.Flusher, encoding string, level int) (*compressedWriter, error) {
+	var (
+		c   compressor
+		err error
+	)
+
+	switch encoding {
+	case "gzip":
+		c, err = gzip.NewWriterLevel(w, level)
+	default:
+		c, err = zlib.NewWriterLevel(w, level) // HTTP's "deflate" is the zlib format
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &compressedWriter{compressor: c, flusher: flusher}, nil
+}
+
+func (c *compressedWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	return c.compressor.Write(p)
+}
+
+// Flush can't report errors as an http.Flusher, they're returned by the next Write.
+func (c *compressedWriter) Flush() {
+	if c.err = c.compressor.Flush(); c.err == nil {
+		c.flusher.Flush()
+	}
+}
+
+// Close writes the compressed stream's trailer.
+func (c *compressedWriter) Close() error {
+	return c.compressor.Close()
+}
+
+// negotiateEncoding picks the content encoding of a stream from the request's Accept-Encoding header,
+// the one with the highest quality, gzip on a tie. It returns "" when neither gzip nor deflate is acceptable.
+func negotiateEncoding(acceptEncoding string) string {
+	qualities := make(map[string]float64)
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+
+		q := 1.0
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				q = parsed
+			}
+		}
+
+		qualities[coding] = q
+	}
+
+	encoding, best := "", 0.0
+	for _, coding := range []string{"gzip", "deflate"} {
+		q, ok := qualities[coding]
+		if !ok {
+			q = qualities["*"]
+		}
+		if q > best {
+			encoding, best = coding, q
+		}
+	}
+
+	return encoding
+}
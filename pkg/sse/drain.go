@@ -0,0 +1,50 @@
+package sse
+
+import (
+	"context"
+)
+
+// EventTypeServerShutdown is the last event sent to clients drained by the hub.
+const EventTypeServerShutdown EventType = "server_shutdown"
+
+type serverShutdownEventData struct {
+	RetryMs int64 `json:"retry_ms"`
+}
+
+// Drain prepares the hub for a shutdown: it stops accepting registrations, sends every client a final
+// server_shutdown event with a jittered retry hint and disconnects them.
+// It then waits until their streams are over, or ctx is done.
+// The hub keeps running, Close must still be called.
+func (h *SSEHub) Drain(ctx context.Context) error {
+	var drained []*sseClient
+
+	err := h.do(func() {
+		h.draining = true
+
+		drained = make([]*sseClient, 0, len(h.order))
+		for len(h.order) > 0 {
+			c := h.order[0]
+			drained = append(drained, c)
+
+			retry := h.jitteredRetry()
+			h.removeClient(c, farewell{
+				retry: retry,
+				// no id, so it doesn't become the client's Last-Event-ID
+				event: Event{Type: EventTypeServerShutdown, Data: serverShutdownEventData{RetryMs: retry.Milliseconds()}},
+			})
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, c := range drained {
+		select {
+		case <-c.unregistered:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
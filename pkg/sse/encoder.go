@@ -0,0 +1,165 @@
+package sse
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidField = errors.New("sse field must not contain line breaks or NULL characters")
+
+// Encoder writes events to w following the EventSource wire format,
+// see https://html.spec.whatwg.org/multipage/server-sent-events.html#event-stream-interpretation.
+//
+// Every frame is written with a single call to w.Write.
+type Encoder struct {
+	w io.Writer
+}
+
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the event's id (its sequence number once published by a hub), type (omitted when empty) and data.
+// String and []byte data are written as is, any other value is JSON-marshalled.
+// Events broadcast by a hub are already encoded, their cached frame is copied out as is.
+func (e *Encoder) Encode(event Event) error {
+	frame := event.frame
+	if frame == nil {
+		var err error
+		if frame, err = MarshalEvent(event); err != nil {
+			return err
+		}
+	}
+
+	return e.write(frame)
+}
+
+// Data writes a message made of data only, dispatched to the client's "message" listeners.
+func (e *Encoder) Data(data any) error {
+	payload, err := marshalData(data)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	writeMultiline(&buf, "data: ", payload)
+	buf.WriteByte('\n')
+
+	return e.write(buf.Bytes())
+}
+
+// Comment writes a comment, ignored by clients. It's useful as a heartbeat.
+func (e *Encoder) Comment(comment string) error {
+	var buf bytes.Buffer
+	writeMultiline(&buf, ": ", []byte(comment))
+	buf.WriteByte('\n')
+
+	return e.write(buf.Bytes())
+}
+
+// Retry sets the client's reconnection delay.
+func (e *Encoder) Retry(retry time.Duration) error {
+	return e.write([]byte("retry: " + strconv.FormatInt(retry.Milliseconds(), 10) + "\n\n"))
+}
+
+func (e *Encoder) write(frame []byte) error {
+	if _, err := e.w.Write(frame); err != nil {
+		return fmt.Errorf("error writing sse frame: %w", err)
+	}
+
+	return nil
+}
+
+// MarshalEvent returns the wire representation of the event, including the blank line that dispatches it.
+func MarshalEvent(event Event) ([]byte, error) {
+	if !validField(event.ID) || !validField(string(event.Type)) {
+		return nil, ErrInvalidField
+	}
+
+	payload, err := marshalData(event.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	if id := event.wireID(); id != "" {
+		buf.WriteString("id: " + id + "\n")
+	}
+
+	if event.Type != EventTypeNone {
+		buf.WriteString("event: " + string(event.Type) + "\n")
+	}
+
+	writeMultiline(&buf, "data: ", payload)
+
+	// end of event (CRITICAL)
+	buf.WriteByte('\n')
+
+	return buf.Bytes(), nil
+}
+
+// encodeEvent returns a copy of the event carrying its wire representation.
+// The frame is shared by every client, so it must never be modified.
+// Encoders writing a different representation of the event must not use it.
+func encodeEvent(event Event) (Event, error) {
+	frame, err := MarshalEvent(event)
+	if err != nil {
+		return event, err
+	}
+
+	event.frame = frame
+
+	return event, nil
+}
+
+func marshalData(data any) ([]byte, error) {
+	switch data := data.(type) {
+	case string:
+		return []byte(data), nil
+	case []byte:
+		return data, nil
+	case json.RawMessage:
+		return data, nil
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling event data: %w", err)
+	}
+
+	return payload, nil
+}
+
+// writeMultiline writes one prefixed line per line of value, splitting on CRLF, LF and CR as the spec requires.
+func writeMultiline(buf *bytes.Buffer, prefix string, value []byte) {
+	for {
+		i := bytes.IndexAny(value, "\r\n")
+		if i < 0 {
+			break
+		}
+
+		buf.WriteString(prefix)
+		buf.Write(value[:i])
+		buf.WriteByte('\n')
+
+		if value[i] == '\r' && i+1 < len(value) && value[i+1] == '\n' {
+			i++
+		}
+		value = value[i+1:]
+	}
+
+	buf.WriteString(prefix)
+	buf.Write(value)
+	buf.WriteByte('\n')
+}
+
+func validField(value string) bool {
+	return !strings.ContainsAny(value, "\r\n\x00")
+}
@@ -2,22 +2,38 @@ package sse
 
 import (
 	"log"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type Event struct {
-	ID        string    `json:"id,omitempty"`
+	ID string `json:"id,omitempty"`
+	// Seq is assigned by the hub when the event is published, monotonically increasing from 1.
+	// It's the event's id on the wire, so clients resume with `Last-Event-ID: <seq>`.
+	Seq       uint64    `json:"seq,omitempty"`
 	Type      EventType `json:"event,omitempty"` // having it serialized as "event" is compliant with the EventSource spec
+	Topic     string    `json:"topic,omitempty"` // routing key, e.g. "metric:<uuid>"; the type also acts as a topic
 	Data      any       `json:"data"`
 	CreatedAt time.Time `json:"-"`
+	// ConflationKey, when set, lets a queued event be replaced by a newer one with the same type and key
+	// while the client is falling behind, e.g. only the latest reading of a metric matters.
+	ConflationKey string `json:"-"`
+	// Recipient and Group, set by SSEHub.SendTo and SSEHub.SendToGroup, restrict the event to a client
+	// or to the members of a group, live and on replay, regardless of their topics and filter.
+	Recipient string `json:"-"`
+	Group     string `json:"-"`
+	// frame is the wire representation cached by the hub, so the event is marshalled once for every client.
+	frame []byte
 }
 
 type EventType string
 
 const (
 	EventTypeNone EventType = ""
+	// EventTypeConnected is the first event of every stream, carrying the client id.
+	EventTypeConnected EventType = "connected"
 )
 
 func NewEvent(eventType EventType, data any) Event {
@@ -38,6 +54,41 @@ func NewEvent(eventType EventType, data any) Event {
 	}
 }
 
+// WithTopic returns a copy of the event routed to the given topic.
+func (e Event) WithTopic(topic string) Event {
+	e.Topic = topic
+	return e
+}
+
+// WithConflationKey returns a copy of the event that conflates with queued events of the same type and key.
+func (e Event) WithConflationKey(key string) Event {
+	e.ConflationKey = key
+	return e
+}
+
+// conflatesWith reports whether the event supersedes the other one.
+// Events without a conflation key conflate by type and topic.
+func (e Event) conflatesWith(other Event) bool {
+	if e.Type != other.Type {
+		return false
+	}
+
+	if e.ConflationKey != "" || other.ConflationKey != "" {
+		return e.ConflationKey == other.ConflationKey
+	}
+
+	return e.Topic == other.Topic
+}
+
+// wireID is the id written on the wire: the sequence number once the event was published by a hub.
+func (e Event) wireID() string {
+	if e.Seq > 0 {
+		return strconv.FormatUint(e.Seq, 10)
+	}
+
+	return e.ID
+}
+
 func (e *Event) IsEmpty() bool {
 	return e.Data == nil
 }
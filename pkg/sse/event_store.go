@@ -1,5 +1,7 @@
 package sse
 
+import "time"
+
 type EventStore interface {
 	// StoreEvent stores the event in the event store.
 	// It's not guaranteed that the event will be stored successfully.
@@ -9,4 +11,22 @@ type EventStore interface {
 
 	// GetEventsAfterID returns the events after the given id.
 	GetEventsAfterID(id string) []Event
+
+	// QueryEvents returns the stored events selected by the query, in sequence order.
+	QueryEvents(query EventQuery) []Event
+}
+
+// EventQuery selects stored events by sequence number, creation time and count.
+// Zero values don't select anything out.
+type EventQuery struct {
+	// AfterSeq selects the events with a greater sequence number.
+	AfterSeq uint64
+	// UntilSeq selects the events with a sequence number up to it.
+	UntilSeq uint64
+	// Since selects the events created at or after it.
+	Since time.Time
+	// Last keeps only the last selected events, at most Last of them.
+	Last int
+	// Limit returns at most Limit events, the first ones once Last is applied.
+	Limit int
 }
@@ -0,0 +1,479 @@
+package sse
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Filter is a per-client expression evaluated against events before they are delivered, e.g.
+//
+//	data.metric_id in ('a', 'b') and data.value > 50
+//
+// Fields are dot-separated paths into the event: "id", "seq", "event", "topic" and "data.<field>...".
+// Supported operators are =, ==, !=, >, >=, <, <=, in (...), and, or, not and parentheses.
+// Literals are numbers, single or double quoted strings, true, false and null.
+type Filter struct {
+	raw  string
+	root filterNode
+}
+
+func ParseFilter(expr string) (*Filter, error) {
+	tokens, err := tokenizeFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &filterParser{tokens: tokens}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != filterTokenEOF {
+		return nil, fmt.Errorf("filter: unexpected %q at position %d", tok.text, tok.pos)
+	}
+
+	return &Filter{raw: expr, root: root}, nil
+}
+
+func (f *Filter) String() string {
+	return f.raw
+}
+
+// Match reports whether the event satisfies the filter.
+func (f *Filter) Match(event Event) bool {
+	return f.matchDocument(eventDocument(event))
+}
+
+func (f *Filter) matchDocument(doc map[string]any) bool {
+	return f.root.eval(doc)
+}
+
+// eventDocument is the generic (JSON) representation of the event that filters are evaluated against.
+func eventDocument(event Event) map[string]any {
+	doc := map[string]any{
+		"id":    event.ID,
+		"seq":   float64(event.Seq),
+		"event": string(event.Type),
+		"topic": event.Topic,
+	}
+
+	raw, err := json.Marshal(event.Data)
+	if err != nil {
+		return doc
+	}
+
+	var data any
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return doc
+	}
+
+	doc["data"] = data
+
+	return doc
+}
+
+type filterNode interface {
+	eval(doc map[string]any) bool
+}
+
+type filterAnd struct{ left, right filterNode }
+
+func (n filterAnd) eval(doc map[string]any) bool { return n.left.eval(doc) && n.right.eval(doc) }
+
+type filterOr struct{ left, right filterNode }
+
+func (n filterOr) eval(doc map[string]any) bool { return n.left.eval(doc) || n.right.eval(doc) }
+
+type filterNot struct{ node filterNode }
+
+func (n filterNot) eval(doc map[string]any) bool { return !n.node.eval(doc) }
+
+type filterOperand interface {
+	value(doc map[string]any) any
+}
+
+type filterPath []string
+
+func (p filterPath) value(doc map[string]any) any {
+	var current any = doc
+
+	for _, key := range p {
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil
+		}
+		current = object[key]
+	}
+
+	return current
+}
+
+type filterLiteral struct{ v any }
+
+func (l filterLiteral) value(map[string]any) any { return l.v }
+
+type filterComparison struct {
+	op          string
+	left, right filterOperand
+}
+
+func (n filterComparison) eval(doc map[string]any) bool {
+	left, right := n.left.value(doc), n.right.value(doc)
+
+	if n.op == "=" || n.op == "!=" {
+		return equalFilterValues(left, right) == (n.op == "=")
+	}
+
+	cmp, ok := compareFilterValues(left, right)
+	if !ok {
+		return false
+	}
+
+	switch n.op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+
+	return false
+}
+
+type filterIn struct {
+	operand filterOperand
+	values  []any
+}
+
+func (n filterIn) eval(doc map[string]any) bool {
+	v := n.operand.value(doc)
+	for _, candidate := range n.values {
+		if equalFilterValues(v, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalFilterValues(a, b any) bool {
+	if cmp, ok := compareFilterValues(a, b); ok {
+		return cmp == 0
+	}
+
+	aBool, aIsBool := a.(bool)
+	bBool, bIsBool := b.(bool)
+	if aIsBool || bIsBool {
+		return aIsBool && bIsBool && aBool == bBool
+	}
+
+	return a == nil && b == nil
+}
+
+func compareFilterValues(a, b any) (int, bool) {
+	switch a := a.(type) {
+	case float64:
+		b, ok := b.(float64)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case a < b:
+			return -1, true
+		case a > b:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		b, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(a, b), true
+	}
+
+	return 0, false
+}
+
+type filterTokenKind int
+
+const (
+	filterTokenEOF filterTokenKind = iota
+	filterTokenIdent
+	filterTokenString
+	filterTokenNumber
+	filterTokenOperator
+	filterTokenLParen
+	filterTokenRParen
+	filterTokenComma
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	text string
+	pos  int
+}
+
+func tokenizeFilter(expr string) ([]filterToken, error) {
+	tokens := make([]filterToken, 0)
+	runes := []rune(expr)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, filterToken{kind: filterTokenLParen, text: "(", pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, filterToken{kind: filterTokenRParen, text: ")", pos: i})
+			i++
+		case r == ',':
+			tokens = append(tokens, filterToken{kind: filterTokenComma, text: ",", pos: i})
+			i++
+		case r == '\'' || r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("filter: unterminated string at position %d", i)
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenString, text: string(runes[i+1 : end]), pos: i})
+			i = end + 1
+		case strings.ContainsRune("=!<>", r):
+			op := string(r)
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				op += "="
+			}
+			switch op {
+			case "!":
+				return nil, fmt.Errorf("filter: unexpected %q at position %d", op, i)
+			case "==":
+				tokens = append(tokens, filterToken{kind: filterTokenOperator, text: "=", pos: i})
+			default:
+				tokens = append(tokens, filterToken{kind: filterTokenOperator, text: op, pos: i})
+			}
+			i += len(op)
+		case unicode.IsDigit(r) || r == '-' || r == '.':
+			end := i + 1
+			for end < len(runes) {
+				c := runes[end]
+				exponent := c == 'e' || c == 'E'
+				if !unicode.IsDigit(c) && c != '.' && !exponent {
+					break
+				}
+				end++
+				// an exponent may be signed, e.g. 1e-5
+				if exponent && end < len(runes) && (runes[end] == '-' || runes[end] == '+') {
+					end++
+				}
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenNumber, text: string(runes[i:end]), pos: i})
+			i = end
+		case unicode.IsLetter(r) || r == '_':
+			end := i + 1
+			for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end]) || runes[end] == '_' || runes[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenIdent, text: string(runes[i:end]), pos: i})
+			i = end
+		default:
+			return nil, fmt.Errorf("filter: unexpected %q at position %d", r, i)
+		}
+	}
+
+	return append(tokens, filterToken{kind: filterTokenEOF, pos: len(runes)}), nil
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() filterToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != filterTokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *filterParser) isKeyword(keyword string) bool {
+	tok := p.peek()
+	return tok.kind == filterTokenIdent && strings.EqualFold(tok.text, keyword)
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isKeyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = filterOr{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isKeyword("and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = filterAnd{left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	if p.isKeyword("not") {
+		p.next()
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return filterNot{node: node}, nil
+	}
+
+	if p.peek().kind == filterTokenLParen {
+		p.next()
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok := p.next(); tok.kind != filterTokenRParen {
+			return nil, fmt.Errorf("filter: expected \")\" at position %d", tok.pos)
+		}
+		return node, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (filterNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.isKeyword("in") {
+		p.next()
+		values, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return filterIn{operand: left, values: values}, nil
+	}
+
+	tok := p.next()
+	if tok.kind != filterTokenOperator {
+		return nil, fmt.Errorf("filter: expected an operator at position %d", tok.pos)
+	}
+
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	return filterComparison{op: tok.text, left: left, right: right}, nil
+}
+
+func (p *filterParser) parseList() ([]any, error) {
+	if tok := p.next(); tok.kind != filterTokenLParen {
+		return nil, fmt.Errorf("filter: expected \"(\" at position %d", tok.pos)
+	}
+
+	values := make([]any, 0)
+
+	for {
+		tok := p.next()
+		v, ok, err := filterLiteralValue(tok)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("filter: expected a literal at position %d", tok.pos)
+		}
+		values = append(values, v)
+
+		switch tok := p.next(); tok.kind {
+		case filterTokenComma:
+			continue
+		case filterTokenRParen:
+			return values, nil
+		default:
+			return nil, fmt.Errorf("filter: expected \",\" or \")\" at position %d", tok.pos)
+		}
+	}
+}
+
+func (p *filterParser) parseOperand() (filterOperand, error) {
+	tok := p.next()
+
+	v, ok, err := filterLiteralValue(tok)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return filterLiteral{v: v}, nil
+	}
+
+	if tok.kind != filterTokenIdent {
+		return nil, fmt.Errorf("filter: expected a field or a literal at position %d", tok.pos)
+	}
+
+	return filterPath(strings.Split(tok.text, ".")), nil
+}
+
+// filterLiteralValue returns the value of a literal token, or false if the token isn't a literal.
+func filterLiteralValue(tok filterToken) (any, bool, error) {
+	switch tok.kind {
+	case filterTokenString:
+		return tok.text, true, nil
+	case filterTokenNumber:
+		n, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, false, fmt.Errorf("filter: invalid number %q at position %d", tok.text, tok.pos)
+		}
+		return n, true, nil
+	case filterTokenIdent:
+		switch strings.ToLower(tok.text) {
+		case "true":
+			return true, true, nil
+		case "false":
+			return false, true, nil
+		case "null":
+			return nil, true, nil
+		}
+	}
+
+	return nil, false, nil
+}
@@ -0,0 +1,99 @@
+package sse
+
+import "testing"
+
+func TestFilterMatch(t *testing.T) {
+	event := NewEvent("metric_reading_created", map[string]any{
+		"metric_id": "a",
+		"value":     42.5,
+		"ok":        true,
+		"nested":    map[string]any{"level": 3},
+	}).WithTopic("metric:1")
+
+	tests := []struct {
+		name string
+		expr string
+		want bool
+	}{
+		{"number comparison", "data.value > 40", true},
+		{"double equals", "data.value == 42.5", true},
+		{"negative exponent", "data.value > 1e-5", true},
+		{"positive exponent", "data.value = 4.25e+1", true},
+		{"uppercase exponent", "data.value < 4.25E1", false},
+		{"negative number", "data.value >= -1e3", true},
+		{"string equality", "data.metric_id = 'a'", true},
+		{"double quoted string", `data.metric_id != "a"`, false},
+		{"nested path", "data.nested.level = 3", true},
+		{"event type", "event = 'metric_reading_created'", true},
+		{"topic", "topic = 'metric:1'", true},
+		{"missing field is null", "data.missing = null", true},
+		{"path through a non object", "data.value.x = null", true},
+		{"boolean", "data.ok = true", true},
+
+		{"and binds tighter than or", "data.value > 40 or data.value > 100 and data.metric_id = 'b'", true},
+		{"parentheses", "(data.value > 40 or data.value > 100) and data.metric_id = 'b'", false},
+		{"and is left associative", "data.ok = true and data.value > 40 and data.metric_id = 'a'", true},
+
+		{"not", "not data.metric_id = 'b'", true},
+		{"double not", "not not data.ok = true", true},
+		{"not binds tighter than and", "not data.value > 100 and data.ok = true", true},
+		{"not a group", "not (data.value > 40 and data.ok = true)", false},
+
+		{"in strings", `data.metric_id in ('a', "b")`, true},
+		{"not in strings", "data.metric_id in ('c')", false},
+		{"in numbers", "data.value in (1, 42.5)", true},
+		{"in mixed types", "data.value in ('42.5', true, null)", false},
+
+		{"number against string", "data.value > '40'", false},
+		{"string against number", "data.metric_id = 1", false},
+		{"ordering booleans", "data.ok > 0", false},
+		{"inequality across types", "data.value != 'x'", true},
+		{"ordering null", "data.missing < 1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := ParseFilter(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseFilter(%q) error: %v", tt.expr, err)
+			}
+
+			if got := filter.Match(event); got != tt.want {
+				t.Errorf("Match(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{"empty", ""},
+		{"missing operand", "data.value >"},
+		{"double operator", "data.value > > 1"},
+		{"missing operator", "data.value 1"},
+		{"unclosed parenthesis", "(data.value > 1"},
+		{"unopened parenthesis", "data.value > 1)"},
+		{"in without list", "data.value in 1"},
+		{"in without comma", "data.value in ('a' 'b')"},
+		{"empty in list", "data.value in ()"},
+		{"field in list", "data.value in (data.other)"},
+		{"unterminated string", "data.metric_id = 'a"},
+		{"bang alone", "data.value ! 1"},
+		{"unknown character", "data.value # 1"},
+		{"malformed number", "data.value > 1.2.3"},
+		{"dangling exponent", "data.value > 1e"},
+		{"dangling and", "data.value > 1 and"},
+		{"dangling not", "not"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseFilter(tt.expr); err == nil {
+				t.Errorf("ParseFilter(%q) succeeded, want an error", tt.expr)
+			}
+		})
+	}
+}
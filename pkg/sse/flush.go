@@ -0,0 +1,99 @@
+package sse
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+)
+
+// DefaultFlushBytes is how many bytes a batching stream buffers before flushing early.
+const DefaultFlushBytes = 64 << 10
+
+// batchWriter coalesces the frames written to a stream, so a burst of events costs a single flush.
+// With a zero interval every batch is flushed right away.
+type batchWriter struct {
+	w        io.Writer
+	flusher  http.Flusher
+	buf      bytes.Buffer
+	interval time.Duration
+	maxBytes int
+	timer    *time.Timer
+	armed    bool
+}
+
+func newBatchWriter(w io.Writer, flusher http.Flusher, interval time.Duration, maxBytes int) *batchWriter {
+	if maxBytes <= 0 {
+		maxBytes = DefaultFlushBytes
+	}
+
+	return &batchWriter{w: w, flusher: flusher, interval: interval, maxBytes: maxBytes}
+}
+
+// Write buffers the frame, flushing once the buffer is over its size limit.
+func (b *batchWriter) Write(p []byte) (int, error) {
+	b.buf.Write(p)
+
+	if b.buf.Len() >= b.maxBytes {
+		if err := b.Flush(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush writes out whatever is buffered and flushes the connection.
+func (b *batchWriter) Flush() error {
+	if b.armed {
+		b.timer.Stop()
+		b.armed = false
+	}
+
+	if b.buf.Len() > 0 {
+		_, err := b.w.Write(b.buf.Bytes())
+		b.buf.Reset()
+		if err != nil {
+			return err
+		}
+	}
+
+	b.flusher.Flush()
+
+	return nil
+}
+
+// FlushSoon flushes at the end of the window opened by the first frame buffered since the last flush.
+func (b *batchWriter) FlushSoon() error {
+	if b.interval <= 0 {
+		return b.Flush()
+	}
+
+	if b.armed || b.buf.Len() == 0 {
+		return nil
+	}
+
+	if b.timer == nil {
+		b.timer = time.NewTimer(b.interval)
+	} else {
+		b.timer.Reset(b.interval)
+	}
+	b.armed = true
+
+	return nil
+}
+
+// Due fires when the flush window is over, it's nil while there is no window open.
+func (b *batchWriter) Due() <-chan time.Time {
+	if !b.armed {
+		return nil
+	}
+
+	return b.timer.C
+}
+
+func (b *batchWriter) Stop() {
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+}
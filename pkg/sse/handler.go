@@ -0,0 +1,423 @@
+package sse
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultMinHeartbeatInterval is the shortest heartbeat interval clients may ask for when none is set.
+const DefaultMinHeartbeatInterval = time.Second
+
+type HandlerOptions struct {
+	// Retry is the reconnection delay sent to clients with the `retry:` field on connect. Zero keeps the browser default.
+	Retry time.Duration
+	// HeartbeatInterval is how often a `: ping` comment is sent to keep idle streams alive. Zero disables heartbeats.
+	HeartbeatInterval time.Duration
+	// MinHeartbeatInterval and MaxHeartbeatInterval bound the interval clients may ask for with `?heartbeat=`.
+	// MinHeartbeatInterval defaults to DefaultMinHeartbeatInterval, so no client can make its stream spin.
+	MinHeartbeatInterval time.Duration
+	MaxHeartbeatInterval time.Duration
+	// Principal resolves the authenticated identity behind a request, e.g. from an API key. Optional.
+	Principal func(r *http.Request) string
+	// Priority resolves the priority class of a request, for CapacityEvictPriority. Optional.
+	Priority func(r *http.Request) int
+	// Groups resolves the groups a request's client is a member of, for SSEHub.SendToGroup. Optional.
+	Groups func(r *http.Request) []string
+	// FlushInterval coalesces the events of a stream for up to this long before flushing them,
+	// trading latency for fewer writes under load. Zero flushes every batch of events right away,
+	// as do clients asking for `?flush=immediate`.
+	FlushInterval time.Duration
+	// FlushBytes flushes a coalescing stream early once this many bytes are buffered. Defaults to DefaultFlushBytes.
+	FlushBytes int
+	// Compression negotiates gzip or deflate with clients sending Accept-Encoding.
+	// The compressor is flushed with every batch of events and heartbeat, at the cost of some memory per stream.
+	Compression bool
+	// CompressionLevel is a compress/flate level. Zero means gzip.BestSpeed.
+	CompressionLevel int
+	// Snapshot returns the current state, sent as a snapshot event. Optional.
+	//
+	// Clients asking for `?snapshot=true` start from it: it's taken with SSEHub.RegisterWithSnapshot,
+	// consistent with the stream position, as long as state changes are broadcast with SSEHub.BroadcastChange.
+	// On reconnection, they resume from their Last-Event-ID.
+	//
+	// Clients asking for `?on_gap=snapshot`, or `?snapshot=true`, start from it instead of replay_gap when their
+	// Last-Event-ID can't be replayed, taken the same way. Events removed by retention while they register still get replay_gap.
+	Snapshot func(r *http.Request) (any, error)
+	// MaxReplay caps the events replayed to a client, with `since` and `last` or after its Last-Event-ID.
+	// `last` is capped to it, clients missing more events after their Last-Event-ID, or asking for more with `since`,
+	// get replay_gap. Defaults to DefaultMaxReplay.
+	MaxReplay int
+	// ReplayPageSize is the number of events read from the store and flushed at once while replaying.
+	// Defaults to DefaultReplayPageSize.
+	ReplayPageSize int
+}
+
+// Handler returns an http.Handler streaming the hub's events, mountable on any router.
+//
+// It supports the `Last-Event-ID` header for replay and the following query parameters:
+//   - since: RFC 3339 time, replays the events stored since then when there's no Last-Event-ID
+//   - last: number of the latest stored events to replay when there's no Last-Event-ID
+//   - topics: comma-separated topics to subscribe to
+//   - filter: filter expression, see Filter
+//   - heartbeat: heartbeat interval, kept within the handler bounds
+//   - flush: "immediate" opts out of the flush window
+//   - client_id: id of a previous connection to resume, receiving the events replayed to it.
+//     It requires resume_token, sent with the id in the connected event
+//   - groups: comma-separated groups to join, on top of HandlerOptions.Groups
+//   - snapshot: "true" to start from a snapshot event, then live events from exactly that point
+//   - on_gap: "snapshot" to get a snapshot instead of replay_gap when Last-Event-ID can't be replayed
+func (h *SSEHub) Handler(opts HandlerOptions) http.Handler {
+	return &streamHandler{hub: h, opts: opts}
+}
+
+type streamHandler struct {
+	hub  *SSEHub
+	opts HandlerOptions
+}
+
+func (s *streamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "flusher not supported")
+		return
+	}
+
+	query := r.URL.Query()
+
+	var filter *Filter
+	if rawFilter := query.Get("filter"); strings.TrimSpace(rawFilter) != "" {
+		parsedFilter, err := ParseFilter(rawFilter)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		filter = parsedFilter
+	}
+
+	heartbeatInterval, err := s.heartbeatInterval(query.Get("heartbeat"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	flushInterval, err := s.flushInterval(query.Get("flush"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	replayReq, err := s.parseReplayRequest(r, query)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	clientID := strings.TrimSpace(query.Get("client_id"))
+	if clientID != "" {
+		if _, err := uuid.Parse(clientID); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid client_id, it must be a UUID")
+			return
+		}
+
+		// ids are public, e.g. in presence events: only their owner may resume them
+		if !s.hub.ValidResumeToken(clientID, query.Get("resume_token")) {
+			writeError(w, http.StatusForbidden, "invalid resume_token for client_id")
+			return
+		}
+	}
+
+	connStartTime := time.Now().UTC()
+
+	var principal string
+	if s.opts.Principal != nil {
+		principal = s.opts.Principal(r)
+	}
+
+	var priority int
+	if s.opts.Priority != nil {
+		priority = s.opts.Priority(r)
+	}
+
+	groups := parseList(query.Get("groups"))
+	if s.opts.Groups != nil {
+		groups = append(groups, s.opts.Groups(r)...)
+	}
+
+	client := NewSSEClient(
+		connStartTime,
+		ClientOptions{
+			ID:         clientID,
+			RemoteAddr: r.RemoteAddr,
+			UserAgent:  r.UserAgent(),
+			Principal:  principal,
+			Priority:   priority,
+			Topics:     ParseTopics(query.Get("topics")),
+			Filter:     filter,
+			Groups:     groups,
+		},
+	)
+
+	// a gap is detected before registering, so the snapshot replacing the replay is consistent too
+	startFromSnapshot := replayReq.takesSnapshot() || (replayReq.snapshotOnGap && s.gapAhead(replayReq))
+
+	var state any
+	if startFromSnapshot {
+		state, err = s.hub.RegisterWithSnapshot(client, func() (any, error) {
+			return s.opts.Snapshot(r)
+		})
+	} else {
+		err = s.hub.Register(client)
+	}
+
+	if err != nil {
+		if errors.Is(err, ErrHubFull) || errors.Is(err, ErrHubDraining) {
+			retryAfter := max(int(math.Ceil(s.hub.RetryAfter().Seconds())), 1)
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		}
+		if errors.Is(err, ErrQuotaExceeded) {
+			writeError(w, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	log.Printf("new client %s connected from %s at %s\n", client.ID(), client.remoteAddr, connStartTime.Format(time.RFC3339))
+
+	defer s.hub.Unregister(client)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	var (
+		stream        io.Writer    = w
+		streamFlusher http.Flusher = flusher
+	)
+
+	if s.opts.Compression {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		if encoding := negotiateEncoding(r.Header.Get("Accept-Encoding")); encoding != "" {
+			level := s.opts.CompressionLevel
+			if level == 0 {
+				level = gzip.BestSpeed
+			}
+
+			compressed, err := newCompressedWriter(w, flusher, encoding, level)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			defer compressed.Close()
+
+			w.Header().Set("Content-Encoding", encoding)
+			stream, streamFlusher = compressed, compressed
+		}
+	}
+
+	out := newBatchWriter(stream, streamFlusher, flushInterval, s.opts.FlushBytes)
+	defer out.Stop()
+
+	encoder := NewEncoder(out)
+
+	if s.opts.Retry > 0 {
+		if err := encoder.Retry(s.opts.Retry); err != nil {
+			return
+		}
+	}
+
+	if err := encoder.Encode(Event{Type: EventTypeConnected, Data: connectedEventData{ClientID: client.ID(), ResumeToken: s.hub.resumeToken(client.ID())}}); err != nil {
+		return
+	}
+
+	if startFromSnapshot {
+		if err := sendEvents(client, encoder, Event{Seq: client.registeredSeq, Type: EventTypeSnapshot, Data: state}); err != nil {
+			return
+		}
+	} else if err := s.replay(client, encoder, out, replayReq); err != nil {
+		return
+	}
+
+	if err := out.Flush(); err != nil {
+		return
+	}
+
+	var heartbeat <-chan time.Time
+	if heartbeatInterval > 0 {
+		heartbeatTicker := time.NewTicker(heartbeatInterval)
+		defer heartbeatTicker.Stop()
+		heartbeat = heartbeatTicker.C
+	}
+
+	// any `return` triggers defer -> unregister client
+	for {
+		select {
+		case <-client.Disconnect():
+			logDisconnection(client)
+
+			// deliver whatever was queued before the hub dropped the client
+			if err := sendEvents(client, encoder, client.Pop()...); err != nil {
+				return
+			}
+
+			if err := sendEvents(client, encoder, client.FinalEvent()); err != nil {
+				return
+			}
+
+			if retryHint := client.RetryHint(); retryHint > 0 {
+				if err := encoder.Retry(retryHint); err != nil {
+					return
+				}
+			}
+
+			if err := encoder.Data("disconnected"); err != nil {
+				return
+			}
+
+			out.Flush()
+			return
+		case <-client.Ready():
+			if err := sendEvents(client, encoder, client.Pop()...); err != nil {
+				return
+			}
+
+			if err := out.FlushSoon(); err != nil {
+				return
+			}
+		case <-out.Due():
+			if err := out.Flush(); err != nil {
+				return
+			}
+		case <-heartbeat:
+			if err := encoder.Comment("ping"); err != nil {
+				return
+			}
+
+			if err := out.Flush(); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			logDisconnection(client)
+			return
+		}
+	}
+}
+
+// snapshotOnGap resolves how a client wants replay gaps handled: "signal" (default) or "snapshot".
+func (s *streamHandler) snapshotOnGap(mode string) (bool, error) {
+	switch strings.TrimSpace(mode) {
+	case "", "signal":
+		return false, nil
+	case "snapshot":
+		if s.opts.Snapshot == nil {
+			return false, errors.New("snapshots are not supported by this stream")
+		}
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid on_gap mode %q, it must be \"signal\" or \"snapshot\"", mode)
+	}
+}
+
+// heartbeatInterval resolves the heartbeat interval asked by the client, kept within the handler bounds.
+func (s *streamHandler) heartbeatInterval(raw string) (time.Duration, error) {
+	if strings.TrimSpace(raw) == "" {
+		return s.opts.HeartbeatInterval, nil
+	}
+
+	interval, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid heartbeat interval: %w", err)
+	}
+
+	if interval <= 0 {
+		return 0, fmt.Errorf("invalid heartbeat interval %q, it must be positive", raw)
+	}
+
+	minInterval := s.opts.MinHeartbeatInterval
+	if minInterval <= 0 {
+		minInterval = DefaultMinHeartbeatInterval
+	}
+
+	if interval < minInterval {
+		interval = minInterval
+	}
+
+	if s.opts.MaxHeartbeatInterval > 0 && interval > s.opts.MaxHeartbeatInterval {
+		interval = s.opts.MaxHeartbeatInterval
+	}
+
+	return interval, nil
+}
+
+// flushInterval resolves the flush window of a connection, "immediate" opting out of it.
+func (s *streamHandler) flushInterval(mode string) (time.Duration, error) {
+	switch strings.TrimSpace(mode) {
+	case "":
+		return s.opts.FlushInterval, nil
+	case "immediate":
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("invalid flush mode %q, only \"immediate\" is supported", mode)
+	}
+}
+
+// ParseTopics parses a comma-separated list of topics, e.g. "metric_created,metric:<uuid>".
+func ParseTopics(raw string) []string {
+	return parseList(raw)
+}
+
+func parseList(raw string) []string {
+	values := make([]string, 0)
+
+	for _, value := range strings.Split(raw, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+type connectedEventData struct {
+	ClientID    string `json:"client_id"`
+	ResumeToken string `json:"resume_token"`
+}
+
+func sendEvents(client *sseClient, encoder *Encoder, events ...Event) error {
+	for _, event := range events {
+		if event.IsEmpty() {
+			continue
+		}
+
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+
+		client.MarkSent(1)
+	}
+
+	return nil
+}
+
+func logDisconnection(client *sseClient) {
+	connDuration := time.Since(client.connectedAt)
+	log.Printf("client %s disconnected after %d seconds", client.ID(), int(math.Ceil(connDuration.Seconds())))
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
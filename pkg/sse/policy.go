@@ -0,0 +1,171 @@
+package sse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SlowClientPolicy decides what the hub does when a client's buffer is full.
+type SlowClientPolicy int
+
+const (
+	// SlowClientDisconnect drops the client.
+	SlowClientDisconnect SlowClientPolicy = iota
+	// SlowClientDropOldest evicts the oldest queued event to make room, like a ring buffer.
+	SlowClientDropOldest
+	// SlowClientDropNewest discards the incoming event.
+	SlowClientDropNewest
+	// SlowClientBlock waits up to HubOptions.SlowClientTimeout for room, then drops the client.
+	// Every client of the same shard waits meanwhile, so the timeout should be kept short.
+	SlowClientBlock
+	// SlowClientConflate replaces the most recent queued event of the same type and conflation key
+	// (or topic, for events without a key), queuing the new one last, falling back to evicting the oldest queued event.
+	SlowClientConflate
+)
+
+var slowClientPolicyNames = map[SlowClientPolicy]string{
+	SlowClientDisconnect: "disconnect",
+	SlowClientDropOldest: "drop_oldest",
+	SlowClientDropNewest: "drop_newest",
+	SlowClientBlock:      "block",
+	SlowClientConflate:   "conflate",
+}
+
+func ParseSlowClientPolicy(name string) (SlowClientPolicy, error) {
+	for policy, policyName := range slowClientPolicyNames {
+		if strings.EqualFold(strings.TrimSpace(name), policyName) {
+			return policy, nil
+		}
+	}
+
+	return SlowClientDisconnect, fmt.Errorf("unknown slow client policy %q", name)
+}
+
+func (p SlowClientPolicy) String() string {
+	if name, ok := slowClientPolicyNames[p]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("SlowClientPolicy(%d)", int(p))
+}
+
+// CapacityPolicy decides what happens when a client registers while the hub is full.
+type CapacityPolicy int
+
+const (
+	// CapacityEvictOldest disconnects the longest-connected client.
+	CapacityEvictOldest CapacityPolicy = iota
+	// CapacityReject turns the new client away, the handler answers 503 with a Retry-After header.
+	CapacityReject
+	// CapacityEvictIdle disconnects the client that went the longest without receiving an event.
+	CapacityEvictIdle
+	// CapacityEvictPriority disconnects the oldest client of the lowest priority class,
+	// as long as it's not above the new client's priority; otherwise the new client is rejected.
+	CapacityEvictPriority
+)
+
+var capacityPolicyNames = map[CapacityPolicy]string{
+	CapacityEvictOldest:   "evict_oldest",
+	CapacityReject:        "reject",
+	CapacityEvictIdle:     "evict_idle",
+	CapacityEvictPriority: "evict_priority",
+}
+
+func ParseCapacityPolicy(name string) (CapacityPolicy, error) {
+	for policy, policyName := range capacityPolicyNames {
+		if strings.EqualFold(strings.TrimSpace(name), policyName) {
+			return policy, nil
+		}
+	}
+
+	return CapacityEvictOldest, fmt.Errorf("unknown capacity policy %q", name)
+}
+
+func (p CapacityPolicy) String() string {
+	if name, ok := capacityPolicyNames[p]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("CapacityPolicy(%d)", int(p))
+}
+
+// capacityVictim picks the client to evict to make room for the newcomer, nil meaning the newcomer is rejected.
+// The newcomer is nil when the hub is shrinking.
+// It must only be called from the hub's run loop.
+func (h *SSEHub) capacityVictim(newcomer *sseClient) *sseClient {
+	if len(h.order) == 0 {
+		return nil
+	}
+
+	switch h.capacityPolicy {
+	case CapacityReject:
+		return nil
+	case CapacityEvictIdle:
+		victim := h.order[0]
+		for _, c := range h.order[1:] {
+			if c.lastActivity.Load() < victim.lastActivity.Load() {
+				victim = c
+			}
+		}
+		return victim
+	case CapacityEvictPriority:
+		victim := h.order[0]
+		for _, c := range h.order[1:] {
+			if c.priority < victim.priority {
+				victim = c
+			}
+		}
+		if newcomer != nil && victim.priority > newcomer.priority {
+			return nil
+		}
+		return victim
+	default:
+		return h.order[0]
+	}
+}
+
+// deliver enqueues the event on the client's buffer, applying the slow client policy when it's full.
+// It returns false when the client must be dropped.
+// It must only be called from the goroutine of the client's shard.
+func (h *SSEHub) deliver(c *sseClient, event Event) bool {
+	// keyed events are conflated as soon as the client falls behind, before its buffer is full
+	if event.ConflationKey != "" && c.queue.underPressure() {
+		if c.queue.replace(event, event.conflatesWith) {
+			h.countDrop(c)
+			return true
+		}
+	}
+
+	if c.queue.push(event) {
+		return true
+	}
+
+	switch h.slowClientPolicy {
+	case SlowClientDropOldest:
+		if c.queue.pushDropOldest(event) {
+			h.countDrop(c)
+		}
+	case SlowClientDropNewest:
+		h.countDrop(c)
+	case SlowClientBlock:
+		if !c.queue.pushWait(event, h.slowClientTimeout) {
+			h.countDrop(c)
+			return false
+		}
+	case SlowClientConflate:
+		if c.queue.replace(event, event.conflatesWith) || c.queue.pushDropOldest(event) {
+			h.countDrop(c)
+		}
+	default:
+		// slow client -> drop it
+		h.countDrop(c)
+		return false
+	}
+
+	return true
+}
+
+func (h *SSEHub) countDrop(c *sseClient) {
+	h.droppedEvents.Add(1)
+	c.eventsDropped.Add(1)
+}
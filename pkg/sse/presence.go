@@ -0,0 +1,81 @@
+package sse
+
+import (
+	"log"
+	"strings"
+	"time"
+)
+
+// SystemTopicPrefix marks the topics of events emitted by the hub itself.
+// They're only delivered to clients subscribing to them explicitly, never to clients without topics.
+const SystemTopicPrefix = "system."
+
+// TopicPresence is the topic of presence events, emitted when HubOptions.Presence is set.
+const TopicPresence = SystemTopicPrefix + "presence"
+
+const (
+	EventTypeClientConnected    EventType = "client_connected"
+	EventTypeClientDisconnected EventType = "client_disconnected"
+	// EventTypeClientGroupsChanged is emitted when a client joins or leaves a group mid-stream, with its new groups.
+	EventTypeClientGroupsChanged EventType = "client_groups_changed"
+)
+
+// PresenceEventData is public, presence events need no credentials: it must not carry the client's principal.
+type PresenceEventData struct {
+	ClientID    string    `json:"client_id"`
+	Groups      []string  `json:"groups"`
+	ConnectedAt time.Time `json:"connected_at"`
+	// DurationSeconds is how long the client was connected, on disconnection.
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+}
+
+// PresenceSnapshot counts the connected clients, overall and per group.
+type PresenceSnapshot struct {
+	Clients int            `json:"clients"`
+	Groups  map[string]int `json:"groups"`
+}
+
+// Presence returns how many clients are connected, overall and per group.
+func (h *SSEHub) Presence() (PresenceSnapshot, error) {
+	var snapshot PresenceSnapshot
+
+	err := h.do(func() {
+		snapshot = PresenceSnapshot{
+			Clients: len(h.clients),
+			Groups:  make(map[string]int, len(h.groups)),
+		}
+
+		for group, members := range h.groups {
+			snapshot.Groups[group] = len(members)
+		}
+	})
+
+	return snapshot, err
+}
+
+// announce publishes a presence event about the client, if presence is enabled.
+// It must only be called from the hub's run loop.
+func (h *SSEHub) announce(c *sseClient, eventType EventType) {
+	if !h.presence {
+		return
+	}
+
+	data := PresenceEventData{
+		ClientID:    c.id,
+		Groups:      c.groupList(),
+		ConnectedAt: c.connectedAt,
+	}
+
+	if eventType == EventTypeClientDisconnected {
+		data.DurationSeconds = time.Since(c.connectedAt).Seconds()
+	}
+
+	if err := h.publish(NewEvent(eventType, data).WithTopic(TopicPresence)); err != nil {
+		log.Printf("error publishing %s event for client %s: %s\n", eventType, c.id, err)
+	}
+}
+
+// isSystem reports whether the event was emitted by the hub, see SystemTopicPrefix.
+func (e Event) isSystem() bool {
+	return strings.HasPrefix(e.Topic, SystemTopicPrefix)
+}
@@ -0,0 +1,148 @@
+package sse
+
+import (
+	"sync"
+	"time"
+)
+
+// eventQueue is a bounded client buffer.
+// Unlike a channel, queued events can be dropped or replaced in place by the slow client policies.
+type eventQueue struct {
+	mu       sync.Mutex
+	events   []Event
+	capacity int
+	closed   bool
+	ready    chan struct{} // signalled when events are pushed or the queue is closed
+	space    chan struct{} // signalled when events are popped
+}
+
+func newEventQueue(capacity int) *eventQueue {
+	return &eventQueue{
+		events:   make([]Event, 0, capacity),
+		capacity: capacity,
+		ready:    make(chan struct{}, 1),
+		space:    make(chan struct{}, 1),
+	}
+}
+
+// push enqueues the event, returning false if the queue is full or closed.
+func (q *eventQueue) push(event Event) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed || len(q.events) >= q.capacity {
+		return false
+	}
+
+	q.events = append(q.events, event)
+	signal(q.ready)
+
+	return true
+}
+
+// pushWait enqueues the event, waiting up to timeout for the consumer to free space.
+func (q *eventQueue) pushWait(event Event, timeout time.Duration) bool {
+	if q.push(event) {
+		return true
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-q.space:
+			if q.push(event) {
+				return true
+			}
+		case <-timer.C:
+			return q.push(event)
+		}
+	}
+}
+
+// pushDropOldest enqueues the event, evicting the oldest queued event if the queue is full.
+// It returns whether an event was evicted.
+func (q *eventQueue) pushDropOldest(event Event) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return false
+	}
+
+	dropped := false
+	if len(q.events) >= q.capacity {
+		q.events = append(q.events[:0], q.events[1:]...)
+		dropped = true
+	}
+
+	q.events = append(q.events, event)
+	signal(q.ready)
+
+	return dropped
+}
+
+// replace removes the most recent queued event matching same and enqueues the event at the tail,
+// so sequence numbers, the events' wire ids, keep increasing: the client's Last-Event-ID never moves back.
+func (q *eventQueue) replace(event Event, same func(queued Event) bool) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return false
+	}
+
+	for i := len(q.events) - 1; i >= 0; i-- {
+		if same(q.events[i]) {
+			q.events = append(q.events[:i], q.events[i+1:]...)
+			q.events = append(q.events, event)
+			signal(q.ready)
+			return true
+		}
+	}
+
+	return false
+}
+
+// pop dequeues every queued event. open is false once the queue has been closed.
+func (q *eventQueue) pop() (events []Event, open bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.events) > 0 {
+		events = q.events
+		q.events = make([]Event, 0, q.capacity)
+		signal(q.space)
+	}
+
+	return events, !q.closed
+}
+
+// underPressure reports whether the queue is at least half full.
+func (q *eventQueue) underPressure() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.events)*2 >= q.capacity
+}
+
+func (q *eventQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.events)
+}
+
+func (q *eventQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	signal(q.ready)
+}
+
+// signal wakes up a waiter without blocking, the channel must be buffered.
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,231 @@
+package sse
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// EventTypeReplayGap tells a resuming client that the events it missed can't be replayed anymore,
+	// e.g. they were removed by retention: it must refetch its state through the REST API.
+	EventTypeReplayGap EventType = "replay_gap"
+
+	DefaultMaxReplay      = 1000
+	DefaultReplayPageSize = 100
+)
+
+type replayGapEventData struct {
+	LastEventID string     `json:"last_event_id,omitempty"`
+	Since       *time.Time `json:"since,omitempty"`
+}
+
+// replayRequest is what a client asked to replay: the events it missed since its Last-Event-ID,
+// or else a snapshot or recent history with `since` and `last`.
+type replayRequest struct {
+	lastEventID   string
+	since         time.Time
+	last          int
+	snapshot      bool
+	snapshotOnGap bool
+}
+
+// takesSnapshot reports whether the client starts from a snapshot rather than a replay. Reconnections resume.
+func (req replayRequest) takesSnapshot() bool {
+	return req.snapshot && req.lastEventID == ""
+}
+
+// parseReplayRequest reads the Last-Event-ID header and the `since`, `last`, `snapshot` and `on_gap` query parameters.
+func (s *streamHandler) parseReplayRequest(r *http.Request, query url.Values) (replayRequest, error) {
+	req := replayRequest{lastEventID: r.Header.Get("Last-Event-ID")}
+
+	snapshotOnGap, err := s.snapshotOnGap(query.Get("on_gap"))
+	if err != nil {
+		return req, err
+	}
+	req.snapshotOnGap = snapshotOnGap
+
+	if raw := strings.TrimSpace(query.Get("since")); raw != "" {
+		if req.since, err = time.Parse(time.RFC3339, raw); err != nil {
+			return req, fmt.Errorf("invalid since, it must be an RFC 3339 time: %w", err)
+		}
+	}
+
+	if raw := strings.TrimSpace(query.Get("last")); raw != "" {
+		if req.last, err = strconv.Atoi(raw); err != nil || req.last <= 0 {
+			return req, fmt.Errorf("invalid last %q, it must be a positive number of events", raw)
+		}
+	}
+
+	if raw := strings.TrimSpace(query.Get("snapshot")); raw != "" {
+		if req.snapshot, err = strconv.ParseBool(raw); err != nil {
+			return req, fmt.Errorf("invalid snapshot %q, it must be a boolean", raw)
+		}
+	}
+
+	if req.snapshot {
+		if s.opts.Snapshot == nil {
+			return req, errors.New("snapshots are not supported by this stream")
+		}
+		if !req.since.IsZero() || req.last > 0 {
+			return req, errors.New("snapshot can't be combined with since or last")
+		}
+		req.snapshotOnGap = true
+	}
+
+	return req, nil
+}
+
+// replay sends what the client asked for, by pages of events each flushed before the next one is read,
+// so a large backlog is never held in memory at once:
+//   - with a Last-Event-ID, every event it missed, or a single replay_gap event when they can't all be replayed
+//   - otherwise, with `since` and/or `last`, the latest stored events. `last` is capped to HandlerOptions.MaxReplay,
+//     and `since` alone gets a single replay_gap event when more events match.
+//     Reconnections send a Last-Event-ID, so they resume rather than replay history again
+//
+// Events published after the client registered are left out, they're already queued live:
+// nothing is lost nor sent twice.
+func (s *streamHandler) replay(client *sseClient, encoder *Encoder, out *batchWriter, req replayRequest) error {
+	if s.hasGap(client.registeredSeq, req) {
+		return sendEvents(client, encoder, replayGap(client, req))
+	}
+
+	query := EventQuery{UntilSeq: client.registeredSeq}
+	remaining := s.maxReplay()
+
+	switch {
+	case client.registeredSeq == 0:
+		return nil // nothing was published yet
+	case req.lastEventID != "":
+		query.AfterSeq, _ = s.resume(client.registeredSeq, req.lastEventID)
+	case !req.since.IsZero() || req.last > 0:
+		if req.last > 0 {
+			remaining = min(req.last, remaining)
+		}
+		query.Since, query.Last = req.since, remaining
+	default:
+		return nil
+	}
+
+	pageSize := s.opts.ReplayPageSize
+	if pageSize <= 0 {
+		pageSize = DefaultReplayPageSize
+	}
+
+	for remaining > 0 {
+		query.Limit = min(pageSize, remaining)
+
+		page := s.hub.QueryEvents(query)
+		for _, event := range page {
+			if client.Subscribed(event) {
+				if err := sendEvents(client, encoder, event); err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(page) < query.Limit {
+			return nil
+		}
+
+		if err := out.Flush(); err != nil {
+			return err
+		}
+
+		remaining -= len(page)
+		// the first page settled where the replay begins
+		query.AfterSeq, query.Last = page[len(page)-1].Seq, 0
+	}
+
+	return nil
+}
+
+// gapAhead reports, before the client registers, whether its replay has a gap, so it can start from a consistent
+// snapshot instead. Retention only removes events: missed events gone now won't be back once it's registered.
+func (s *streamHandler) gapAhead(req replayRequest) bool {
+	seq, err := s.hub.lastSeq()
+	return err == nil && s.hasGap(seq, req)
+}
+
+// hasGap reports whether the replay the client asked for can't be complete up to the given position,
+// see resume and exceedsReplay.
+func (s *streamHandler) hasGap(untilSeq uint64, req replayRequest) bool {
+	switch {
+	case req.lastEventID != "":
+		if untilSeq == 0 {
+			return req.lastEventID != "0" // nothing was published yet
+		}
+		_, gap := s.resume(untilSeq, req.lastEventID)
+		return gap
+	case !req.since.IsZero() && req.last == 0:
+		return s.exceedsReplay(untilSeq, req.since)
+	}
+
+	return false
+}
+
+func (s *streamHandler) maxReplay() int {
+	if s.opts.MaxReplay <= 0 {
+		return DefaultMaxReplay
+	}
+
+	return s.opts.MaxReplay
+}
+
+// resume resolves the sequence number the client's Last-Event-ID stands for: a sequence number or,
+// for streams started before sequence numbers, an event id.
+//
+// gap is true when some of the events missed up to untilSeq aren't stored anymore, there are more than
+// HandlerOptions.MaxReplay of them, or lastEventID is unknown.
+func (s *streamHandler) resume(untilSeq uint64, lastEventID string) (afterSeq uint64, gap bool) {
+	seq, err := strconv.ParseUint(lastEventID, 10, 64)
+	if err != nil {
+		missed := s.hub.GetEventsAfterID(lastEventID)
+		if missed == nil {
+			return 0, true
+		}
+		if len(missed) == 0 || missed[0].Seq > untilSeq {
+			return untilSeq, false // caught up
+		}
+		seq = missed[0].Seq - 1
+	}
+
+	switch {
+	case seq > untilSeq:
+		return 0, true // from the future, the hub was likely restarted
+	case seq == untilSeq:
+		return seq, false
+	case untilSeq-seq > uint64(s.maxReplay()):
+		return 0, true
+	}
+
+	first := s.hub.QueryEvents(EventQuery{AfterSeq: seq, UntilSeq: untilSeq, Limit: 1})
+	if len(first) == 0 || first[0].Seq > seq+1 {
+		return 0, true
+	}
+
+	return seq, false
+}
+
+// exceedsReplay reports whether more than HandlerOptions.MaxReplay events stored up to untilSeq were created
+// since the given time: the first of them isn't among the latest ones.
+func (s *streamHandler) exceedsReplay(untilSeq uint64, since time.Time) bool {
+	first := s.hub.QueryEvents(EventQuery{UntilSeq: untilSeq, Since: since, Limit: 1})
+	latest := s.hub.QueryEvents(EventQuery{UntilSeq: untilSeq, Since: since, Last: s.maxReplay(), Limit: 1})
+
+	return len(first) > 0 && first[0].Seq != latest[0].Seq
+}
+
+// replayGap replaces a replay that has a gap, moving the client's Last-Event-ID to where live events begin.
+func replayGap(client *sseClient, req replayRequest) Event {
+	data := replayGapEventData{LastEventID: req.lastEventID}
+	if req.lastEventID == "" && !req.since.IsZero() {
+		data.Since = &req.since
+	}
+
+	return Event{Seq: client.registeredSeq, Type: EventTypeReplayGap, Data: data}
+}
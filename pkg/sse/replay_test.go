@@ -0,0 +1,170 @@
+package sse
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore keeps every event, retention would make gaps legitimate.
+type memoryStore struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (s *memoryStore) StoreEvent(event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, event)
+}
+
+func (s *memoryStore) GetEventsAfterID(id string) []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, event := range s.events {
+		if event.ID == id {
+			return append([]Event{}, s.events[i+1:]...)
+		}
+	}
+
+	return nil
+}
+
+func (s *memoryStore) QueryEvents(query EventQuery) []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var selected []Event
+	for _, event := range s.events {
+		if event.Seq > query.AfterSeq && (query.UntilSeq == 0 || event.Seq <= query.UntilSeq) && !event.CreatedAt.Before(query.Since) {
+			selected = append(selected, event)
+		}
+	}
+
+	if query.Last > 0 && len(selected) > query.Last {
+		selected = selected[len(selected)-query.Last:]
+	}
+	if query.Limit > 0 && len(selected) > query.Limit {
+		selected = selected[:query.Limit]
+	}
+
+	return selected
+}
+
+// TestResumeIsConsecutive reconnects with Last-Event-ID while events are broadcast:
+// replayed and live events must join without losing nor repeating any of them.
+func TestResumeIsConsecutive(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hub := NewHub(ctx, HubOptions{EventStore: &memoryStore{}})
+	defer hub.Close()
+
+	server := httptest.NewServer(hub.Handler(HandlerOptions{}))
+	defer server.Close()
+
+	stop := make(chan struct{})
+	broadcasting := make(chan struct{})
+	go func() {
+		defer close(broadcasting)
+		for tick := 0; ; tick++ {
+			select {
+			case <-stop:
+				return
+			default:
+				hub.Broadcast(NewEvent("tick", tick))
+				time.Sleep(100 * time.Microsecond)
+			}
+		}
+	}()
+	defer func() {
+		close(stop)
+		<-broadcasting
+	}()
+
+	const (
+		reconnections    = 20
+		eventsPerConnect = 25
+	)
+
+	var ids []uint64
+	lastEventID := ""
+
+	for range reconnections {
+		received := readEvents(t, server.URL, lastEventID, eventsPerConnect)
+		ids = append(ids, received...)
+		lastEventID = strconv.FormatUint(ids[len(ids)-1], 10)
+	}
+
+	for i := 1; i < len(ids); i++ {
+		if ids[i] != ids[i-1]+1 {
+			t.Fatalf("id %d followed by %d, want consecutive ids", ids[i-1], ids[i])
+		}
+	}
+}
+
+// readEvents connects with the given Last-Event-ID and returns the ids of the first count events received.
+func readEvents(t *testing.T, url, lastEventID string, count int) []uint64 {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var (
+		ids       []uint64
+		id, event string
+	)
+
+	scanner := bufio.NewScanner(resp.Body)
+	for len(ids) < count && scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case line == "":
+			switch EventType(event) {
+			case EventTypeConnected:
+			case EventTypeReplayGap:
+				t.Fatalf("replay_gap after Last-Event-ID %q, every event is stored", lastEventID)
+			case "tick":
+				seq, err := strconv.ParseUint(id, 10, 64)
+				if err != nil {
+					t.Fatalf("event id %q isn't a sequence number", id)
+				}
+				ids = append(ids, seq)
+			}
+			id, event = "", ""
+		}
+	}
+
+	if len(ids) < count {
+		t.Fatalf("received %d events after Last-Event-ID %q, want %d: %v", len(ids), lastEventID, count, scanner.Err())
+	}
+
+	return ids
+}
@@ -0,0 +1,31 @@
+package sse
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// newResumeSecret generates the key signing resume tokens when HubOptions.ResumeSecret isn't set.
+func newResumeSecret() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic("sse: can't generate a resume secret: " + err.Error())
+	}
+
+	return secret
+}
+
+// resumeToken proves a request owns a client id, to resume it with `client_id`.
+// It's sent to the client in the connected event.
+func (h *SSEHub) resumeToken(clientID string) string {
+	mac := hmac.New(sha256.New, h.resumeSecret)
+	mac.Write([]byte(clientID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ValidResumeToken reports whether the token was issued for the client id, proving the caller owns it.
+func (h *SSEHub) ValidResumeToken(clientID, token string) bool {
+	return hmac.Equal([]byte(h.resumeToken(clientID)), []byte(token))
+}
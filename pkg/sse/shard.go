@@ -0,0 +1,146 @@
+package sse
+
+import "sync"
+
+// DefaultShardInboxSize is the number of messages a shard queues before the hub's run loop waits for it.
+const DefaultShardInboxSize = 1024
+
+// hubShard owns the delivery state of a subset of the hub's clients, so broadcasts fan out in parallel.
+// Its goroutine handles messages in the order the run loop sends them: a client added before an event
+// is broadcast receives it, a client removed before doesn't.
+type hubShard struct {
+	hub      *SSEHub
+	inbox    chan shardMessage
+	wildcard map[*sseClient]struct{}            // clients without topics, they receive every event
+	topics   map[string]map[*sseClient]struct{} // topic -> subscribed clients
+}
+
+// shardMessage carries exactly one of add, remove or envelope.
+// An envelope with a target is delivered to that client only, regardless of its topics and filter.
+type shardMessage struct {
+	add      *sseClient
+	remove   *sseClient
+	envelope *envelope
+	target   *sseClient
+}
+
+// envelope is an event broadcast to every shard, with what's worth computing once for all of them.
+type envelope struct {
+	event   Event
+	docOnce sync.Once
+	doc     map[string]any
+}
+
+func newEnvelope(event Event) *envelope {
+	return &envelope{event: event}
+}
+
+// document decodes the event for filters, once and only if some subscriber has a filter.
+func (e *envelope) document() map[string]any {
+	e.docOnce.Do(func() {
+		e.doc = eventDocument(e.event)
+	})
+
+	return e.doc
+}
+
+func newHubShard(h *SSEHub) *hubShard {
+	return &hubShard{
+		hub:      h,
+		inbox:    make(chan shardMessage, DefaultShardInboxSize),
+		wildcard: make(map[*sseClient]struct{}),
+		topics:   make(map[string]map[*sseClient]struct{}),
+	}
+}
+
+// run handles messages until the inbox is closed by the hub.
+func (s *hubShard) run(wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for msg := range s.inbox {
+		switch {
+		case msg.add != nil:
+			s.subscribe(msg.add)
+		case msg.remove != nil:
+			s.unsubscribe(msg.remove)
+		case msg.target != nil:
+			s.deliver(msg.target, msg.envelope.event)
+		case msg.envelope != nil:
+			s.fanOut(msg.envelope)
+		}
+	}
+}
+
+func (s *hubShard) fanOut(e *envelope) {
+	s.forEachSubscriber(e.event, func(c *sseClient) {
+		if c.filter != nil && !c.filter.matchDocument(e.document()) {
+			return
+		}
+
+		s.deliver(c, e.event)
+	})
+}
+
+// deliver drops the client when the slow client policy gives up on it.
+// The hub forgets about it once its handler unregisters it.
+func (s *hubShard) deliver(c *sseClient, event Event) {
+	if c.disconnected() {
+		return // dropped by the hub, its removal is on the way
+	}
+
+	if !s.hub.deliver(c, event) {
+		s.unsubscribe(c)
+		c.disconnect(farewell{})
+	}
+}
+
+func (s *hubShard) subscribe(c *sseClient) {
+	if len(c.topics) == 0 {
+		s.wildcard[c] = struct{}{}
+		return
+	}
+
+	for topic := range c.topics {
+		if s.topics[topic] == nil {
+			s.topics[topic] = make(map[*sseClient]struct{})
+		}
+		s.topics[topic][c] = struct{}{}
+	}
+}
+
+func (s *hubShard) unsubscribe(c *sseClient) {
+	delete(s.wildcard, c)
+
+	for topic := range c.topics {
+		delete(s.topics[topic], c)
+		if len(s.topics[topic]) == 0 {
+			delete(s.topics, topic)
+		}
+	}
+}
+
+// forEachSubscriber calls fn once for every client of the shard interested in the event.
+// fn is allowed to unsubscribe the client.
+func (s *hubShard) forEachSubscriber(event Event, fn func(c *sseClient)) {
+	if !event.isSystem() {
+		for c := range s.wildcard {
+			fn(c)
+		}
+	}
+
+	typeSubscribers := s.topics[string(event.Type)]
+	for c := range typeSubscribers {
+		fn(c)
+	}
+
+	if event.Topic == "" || event.Topic == string(event.Type) {
+		return
+	}
+
+	for c := range s.topics[event.Topic] {
+		if _, ok := typeSubscribers[c]; ok {
+			continue // already delivered by type
+		}
+		fn(c)
+	}
+}
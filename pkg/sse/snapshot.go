@@ -0,0 +1,54 @@
+package sse
+
+import (
+	"log"
+)
+
+// EventTypeSnapshot carries the state from HandlerOptions.Snapshot, live events follow from there.
+const EventTypeSnapshot EventType = "snapshot"
+
+// BroadcastChange applies a state change, then broadcasts the event it returns once published,
+// so snapshots taken with RegisterWithSnapshot either include the change and precede its event, or neither.
+// Nothing is broadcast when the change fails, its error is returned.
+//
+// Changes don't wait for each other, only for snapshots being taken.
+func (h *SSEHub) BroadcastChange(change func() (Event, error)) error {
+	h.changes.RLock()
+	defer h.changes.RUnlock()
+
+	event, err := change()
+	if err != nil {
+		return err
+	}
+
+	// like Broadcast, the event is discarded if the hub is closed
+	h.do(func() {
+		if err := h.publish(event); err != nil {
+			log.Printf("error encoding event %s, it won't be broadcast: %s\n", event.ID, err)
+		}
+	})
+
+	return nil
+}
+
+// RegisterWithSnapshot registers the client, then takes a snapshot of the state exactly at its registeredSeq:
+// every change broadcast with BroadcastChange before it is part of the snapshot, every later one is delivered live.
+// The client is unregistered if the snapshot fails.
+//
+// Changes wait for the snapshot to be taken, it must be quick.
+func (h *SSEHub) RegisterWithSnapshot(c *sseClient, snapshot func() (any, error)) (any, error) {
+	h.changes.Lock()
+	defer h.changes.Unlock()
+
+	if err := h.Register(c); err != nil {
+		return nil, err
+	}
+
+	state, err := snapshot()
+	if err != nil {
+		h.Unregister(c)
+		return nil, err
+	}
+
+	return state, nil
+}
@@ -1,90 +1,681 @@
 package sse
 
-import "sync"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const DefaultSlowClientTimeout = 100 * time.Millisecond
 
 var (
-	sseHubSingleton *SSEHub
-	sseHubOnce      sync.Once
+	ErrHubClosed      = errors.New("sse hub is closed")
+	ErrClientNotFound = errors.New("sse client not found")
+	ErrHubFull        = errors.New("sse hub is at capacity")
+	ErrQuotaExceeded  = errors.New("sse connection quota exceeded")
+	ErrHubDraining    = errors.New("sse hub is draining")
 )
 
+// QuotaError tells which quota turned a client away. It matches ErrQuotaExceeded with errors.Is.
+type QuotaError struct {
+	Scope string // "ip" or "principal"
+	Key   string
+	Limit int
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s: at most %d concurrent streams allowed for %s %s", ErrQuotaExceeded, e.Limit, e.Scope, e.Key)
+}
+
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// QuotaOptions limits concurrent streams per remote IP and per authenticated principal. Zero means no limit.
+type QuotaOptions struct {
+	PerIP        int
+	PerPrincipal int
+	// PrincipalLimits overrides PerPrincipal for specific principals, e.g. per API key.
+	PrincipalLimits map[string]int
+}
+
+type HubOptions struct {
+	EventStore EventStore
+	// MaxClients is the maximum number of concurrent clients. Zero means no limit. It can be changed with SetMaxClients.
+	MaxClients int
+	// CapacityPolicy decides who gives way when a client registers while the hub is full. Defaults to CapacityEvictOldest.
+	CapacityPolicy CapacityPolicy
+	// Quotas are enforced at registration, before the capacity policy.
+	Quotas QuotaOptions
+	// SlowClientPolicy applies when a client's buffer is full. Defaults to SlowClientDisconnect.
+	SlowClientPolicy SlowClientPolicy
+	// SlowClientTimeout is how long SlowClientBlock waits for room. Defaults to DefaultSlowClientTimeout.
+	SlowClientTimeout time.Duration
+	// EvictionRetry and EvictionRetryJitter make up the `retry:` hint sent to clients evicted for capacity
+	// or during shutdown: EvictionRetry plus a random delay up to EvictionRetryJitter, so they don't all
+	// reconnect at once. No hint is sent when both are zero.
+	EvictionRetry       time.Duration
+	EvictionRetryJitter time.Duration
+	// Presence emits client_connected and client_disconnected events on TopicPresence.
+	Presence bool
+	// Shards is the number of goroutines broadcasts fan out on, each owning a subset of the clients.
+	// Defaults to GOMAXPROCS.
+	Shards int
+	// ResumeSecret signs the resume tokens clients need to resume their id with `client_id`.
+	// A random one is generated when empty, tokens then don't outlive the hub.
+	ResumeSecret []byte
+}
+
 type SSEHub struct {
 	eventStore EventStore
 	clients    map[*sseClient]struct{}
+	byID       map[string]*sseClient
 	order      []*sseClient
-	Register   chan *sseClient
-	Unregister chan *sseClient
-	Broadcast  chan Event
+	groups     map[string]map[*sseClient]struct{} // group -> members
+	shards     []*hubShard
+	nextShard  int
+	shardsDone sync.WaitGroup
+	register   chan registration
+	unregister chan *sseClient
+	broadcast  chan Event
+	ops        chan func() // run inside the run loop, with exclusive access to the clients
 	maxClients int
+	cancel     context.CancelFunc
+	done       chan struct{}
+
+	capacityPolicy      CapacityPolicy
+	rejectedConnections atomic.Uint64
+
+	quotas           QuotaOptions
+	ipStreams        map[string]int
+	principalStreams map[string]int
+	quotaRejections  atomic.Uint64
+
+	slowClientPolicy  SlowClientPolicy
+	slowClientTimeout time.Duration
+	droppedEvents     atomic.Uint64
+
+	evictionRetry       time.Duration
+	evictionRetryJitter time.Duration
+
+	presence     bool
+	draining     bool
+	resumeSecret []byte
+
+	changes sync.RWMutex // orders BroadcastChange against RegisterWithSnapshot
+
+	seq uint64 // sequence number of the last published event
 }
 
-func InitializeSSEHub(eventStore EventStore, maxClients int) {
-	if sseHubSingleton != nil {
-		return
+// NewHub creates an independent hub and starts its run loop.
+// The hub stops when ctx is cancelled or Close is called, disconnecting every registered client.
+func NewHub(ctx context.Context, opts HubOptions) *SSEHub {
+	ctx, cancel := context.WithCancel(ctx)
+
+	slowClientTimeout := opts.SlowClientTimeout
+	if slowClientTimeout <= 0 {
+		slowClientTimeout = DefaultSlowClientTimeout
+	}
+
+	shards := opts.Shards
+	if shards <= 0 {
+		shards = runtime.GOMAXPROCS(0)
+	}
+
+	h := &SSEHub{
+		eventStore: opts.EventStore,
+		clients:    make(map[*sseClient]struct{}),
+		byID:       make(map[string]*sseClient),
+		groups:     make(map[string]map[*sseClient]struct{}),
+		shards:     make([]*hubShard, shards),
+		register:   make(chan registration),
+		unregister: make(chan *sseClient),
+		broadcast:  make(chan Event),
+		ops:        make(chan func()),
+		maxClients: opts.MaxClients,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+
+		capacityPolicy: opts.CapacityPolicy,
+
+		quotas:           opts.Quotas,
+		ipStreams:        make(map[string]int),
+		principalStreams: make(map[string]int),
+
+		slowClientPolicy:  opts.SlowClientPolicy,
+		slowClientTimeout: slowClientTimeout,
+
+		evictionRetry:       opts.EvictionRetry,
+		evictionRetryJitter: opts.EvictionRetryJitter,
+
+		presence:     opts.Presence,
+		resumeSecret: opts.ResumeSecret,
+	}
+
+	if len(h.resumeSecret) == 0 {
+		h.resumeSecret = newResumeSecret()
+	}
+
+	for i := range h.shards {
+		h.shards[i] = newHubShard(h)
+		h.shardsDone.Add(1)
+		go h.shards[i].run(&h.shardsDone)
+	}
+
+	go h.run(ctx)
+
+	return h
+}
+
+// Close stops the hub and waits for its run loop to exit.
+func (h *SSEHub) Close() {
+	h.cancel()
+	<-h.done
+}
+
+// Done is closed once the hub has stopped.
+func (h *SSEHub) Done() <-chan struct{} {
+	return h.done
+}
+
+type registration struct {
+	client *sseClient
+	result chan error
+}
+
+// Register adds the client to the hub. It fails with ErrQuotaExceeded when the client is over one of its quotas,
+// with ErrHubFull when the capacity policy rejects it, or with ErrHubDraining once Drain was called.
+// A client registering with the id of a connected one takes over its identity, the previous one is disconnected.
+func (h *SSEHub) Register(c *sseClient) error {
+	r := registration{client: c, result: make(chan error, 1)}
+
+	select {
+	case h.register <- r:
+		return <-r.result
+	case <-h.done:
+		return ErrHubClosed
+	}
+}
+
+// Unregister removes the client once its stream is over.
+func (h *SSEHub) Unregister(c *sseClient) {
+	defer c.markUnregistered()
+
+	select {
+	case h.unregister <- c:
+	case <-h.done:
+	}
+}
+
+// Broadcast sends the event to every client subscribed to its type or topic,
+// or to its recipient or group only when they're set.
+// The event is encoded once for all of them; it's discarded if it can't be encoded.
+// Events broadcasted after the hub is closed are discarded.
+func (h *SSEHub) Broadcast(event Event) {
+	select {
+	case h.broadcast <- event:
+	case <-h.done:
 	}
+}
+
+// Clients lists the connected clients, oldest first.
+func (h *SSEHub) Clients() ([]ClientInfo, error) {
+	var clients []ClientInfo
 
-	sseHubOnce.Do(func() {
-		sseHubSingleton = &SSEHub{
-			eventStore: eventStore,
-			clients:    make(map[*sseClient]struct{}),
-			Register:   make(chan *sseClient),
-			Unregister: make(chan *sseClient),
-			Broadcast:  make(chan Event),
-			maxClients: maxClients,
+	err := h.do(func() {
+		clients = make([]ClientInfo, 0, len(h.order))
+		for _, c := range h.order {
+			clients = append(clients, c.Info())
 		}
+	})
 
-		go sseHubSingleton.run()
+	return clients, err
+}
+
+// Disconnect force-disconnects the client with the given id.
+func (h *SSEHub) Disconnect(clientID string) error {
+	found := false
+
+	err := h.do(func() {
+		if c, ok := h.byID[clientID]; ok {
+			found = true
+			h.evictClient(c)
+		}
 	})
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return ErrClientNotFound
+	}
+
+	return nil
 }
 
-func GetSSEHub() *SSEHub {
-	if sseHubSingleton == nil {
-		panic("SSEHub not initialized")
+// SendTo delivers the event to a single client, regardless of its topics and filter.
+// The event is stored and only replayed to a client resuming the same id.
+// It returns the sequence number the event was published with, its id on the wire.
+func (h *SSEHub) SendTo(clientID string, event Event) (uint64, error) {
+	event.Recipient, event.Group = clientID, ""
+
+	var (
+		seq        uint64
+		publishErr error
+	)
+
+	err := h.do(func() {
+		if _, ok := h.byID[clientID]; !ok {
+			publishErr = ErrClientNotFound
+			return
+		}
+		if publishErr = h.publish(event); publishErr == nil {
+			seq = h.seq
+		}
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	return sseHubSingleton
+	return seq, publishErr
 }
 
-func (h *SSEHub) run() {
+// SendToGroup delivers the event to the current members of the group, regardless of their topics and filter.
+// The event is stored and only replayed to members of the group.
+// It returns the sequence number the event was published with, its id on the wire.
+func (h *SSEHub) SendToGroup(group string, event Event) (uint64, error) {
+	event.Recipient, event.Group = "", group
+
+	var (
+		seq        uint64
+		publishErr error
+	)
+
+	err := h.do(func() {
+		if publishErr = h.publish(event); publishErr == nil {
+			seq = h.seq
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return seq, publishErr
+}
+
+// JoinGroup adds a connected client to the group, without reconnecting. Joining a group twice is a no-op.
+func (h *SSEHub) JoinGroup(clientID, group string) error {
+	return h.updateMembership(clientID, group, true)
+}
+
+// LeaveGroup removes a connected client from the group. Leaving a group the client isn't a member of is a no-op.
+func (h *SSEHub) LeaveGroup(clientID, group string) error {
+	return h.updateMembership(clientID, group, false)
+}
+
+func (h *SSEHub) updateMembership(clientID, group string, member bool) error {
+	found := false
+
+	err := h.do(func() {
+		c, ok := h.byID[clientID]
+		if !ok {
+			return
+		}
+		found = true
+
+		if !c.setGroup(group, member) {
+			return
+		}
+
+		if member {
+			h.indexMember(group, c)
+		} else {
+			h.unindexMember(group, c)
+		}
+
+		h.announce(c, EventTypeClientGroupsChanged)
+	})
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return ErrClientNotFound
+	}
+
+	return nil
+}
+
+// SetMaxClients changes the client limit at runtime. Zero means no limit.
+// Clients over the new limit are evicted according to the capacity policy.
+func (h *SSEHub) SetMaxClients(maxClients int) error {
+	return h.do(func() {
+		h.maxClients = max(maxClients, 0)
+
+		for h.maxClients > 0 && len(h.clients) > h.maxClients {
+			victim := h.capacityVictim(nil)
+			if victim == nil {
+				victim = h.order[0] // rejecting newcomers doesn't apply, shed the oldest ones
+			}
+			h.evictClient(victim)
+		}
+	})
+}
+
+type HubStats struct {
+	Clients             int    `json:"clients"`
+	MaxClients          int    `json:"max_clients"`
+	CapacityPolicy      string `json:"capacity_policy"`
+	SlowClientPolicy    string `json:"slow_client_policy"`
+	DroppedEvents       uint64 `json:"dropped_events"`
+	RejectedConnections uint64 `json:"rejected_connections"`
+	QuotaRejections     uint64 `json:"quota_rejections"`
+	Shards              int    `json:"shards"`
+	LastSeq             uint64 `json:"last_seq"`
+}
+
+func (h *SSEHub) Stats() (HubStats, error) {
+	var stats HubStats
+
+	err := h.do(func() {
+		stats = HubStats{
+			Clients:             len(h.clients),
+			MaxClients:          h.maxClients,
+			CapacityPolicy:      h.capacityPolicy.String(),
+			SlowClientPolicy:    h.slowClientPolicy.String(),
+			DroppedEvents:       h.droppedEvents.Load(),
+			RejectedConnections: h.rejectedConnections.Load(),
+			QuotaRejections:     h.quotaRejections.Load(),
+			Shards:              len(h.shards),
+			LastSeq:             h.seq,
+		}
+	})
+
+	return stats, err
+}
+
+// RetryAfter suggests how long a rejected client should wait before trying again.
+func (h *SSEHub) RetryAfter() time.Duration {
+	return h.jitteredRetry()
+}
+
+// do runs op inside the run loop and waits for it to complete.
+func (h *SSEHub) do(op func()) error {
+	completed := make(chan struct{})
+
+	select {
+	case h.ops <- func() {
+		op()
+		close(completed)
+	}:
+	case <-h.done:
+		return ErrHubClosed
+	}
+
+	<-completed
+
+	return nil
+}
+
+// run owns the registry of clients: admission, management and the order of events.
+// Delivery is left to the shards.
+func (h *SSEHub) run(ctx context.Context) {
+	defer close(h.done)
+
 	for {
 		select {
-		case c := <-h.Register:
-			if len(h.clients) >= h.maxClients {
-				oldestClient := h.order[0]
-				h.order = h.order[1:]
-				delete(h.clients, oldestClient)
-				close(oldestClient.ch)
-				oldestClient.disconnectChan <- struct{}{}
+		case r := <-h.register:
+			r.result <- h.admit(r.client)
+		case op := <-h.ops:
+			op()
+		case c := <-h.unregister:
+			h.removeClient(c, farewell{})
+		case event := <-h.broadcast:
+			if err := h.publish(event); err != nil {
+				log.Printf("error encoding event %s, it won't be broadcast: %s\n", event.ID, err)
 			}
-			h.clients[c] = struct{}{}
-			h.order = append(h.order, c)
-		case c := <-h.Unregister:
-			if _, ok := h.clients[c]; ok {
-				delete(h.clients, c)
-				close(c.ch)
-				c.disconnectChan <- struct{}{}
-				for i, v := range h.order {
-					if v == c {
-						h.order = append(h.order[:i], h.order[i+1:]...)
-						break
-					}
-				}
+		case <-ctx.Done():
+			for len(h.order) > 0 {
+				h.evictClient(h.order[0])
 			}
-		case event := <-h.Broadcast:
-			h.eventStore.StoreEvent(event)
-			for c := range h.clients {
-				select {
-				case c.ch <- event:
-				default:
-					// slow client -> drop it
-					delete(h.clients, c)
-					close(c.ch)
-					c.disconnectChan <- struct{}{}
-				}
+			for _, shard := range h.shards {
+				close(shard.inbox)
 			}
+			h.shardsDone.Wait()
+			return
 		}
 	}
 }
 
+// publish numbers, encodes and stores the event, then hands it to the shards of its audience.
+func (h *SSEHub) publish(event Event) error {
+	event.Seq = h.seq + 1
+
+	event, err := encodeEvent(event)
+	if err != nil {
+		return err
+	}
+
+	h.seq = event.Seq
+
+	if h.eventStore != nil {
+		h.eventStore.StoreEvent(event)
+	}
+
+	e := newEnvelope(event)
+
+	switch {
+	case event.Recipient != "":
+		if c, ok := h.byID[event.Recipient]; ok {
+			c.shard.inbox <- shardMessage{target: c, envelope: e}
+		}
+	case event.Group != "":
+		for c := range h.groups[event.Group] {
+			c.shard.inbox <- shardMessage{target: c, envelope: e}
+		}
+	default:
+		for _, shard := range h.shards {
+			shard.inbox <- shardMessage{envelope: e}
+		}
+	}
+
+	return nil
+}
+
+// admit registers the client, making room for it according to the capacity policy.
+func (h *SSEHub) admit(c *sseClient) error {
+	if h.draining {
+		return ErrHubDraining
+	}
+
+	// resumed elsewhere, most likely a reconnection: the previous connection gives way to it,
+	// only once it's admitted, so it doesn't count against the quotas nor the capacity
+	previous := h.byID[c.id]
+
+	if err := h.checkQuotas(c, previous); err != nil {
+		h.quotaRejections.Add(1)
+		log.Printf("client %s rejected: %s\n", c.id, err)
+		return err
+	}
+
+	occupied := len(h.clients)
+	if previous != nil {
+		occupied--
+	}
+
+	for h.maxClients > 0 && occupied >= h.maxClients {
+		victim := h.capacityVictim(c)
+		if victim == nil {
+			h.rejectedConnections.Add(1)
+			return ErrHubFull
+		}
+		h.evictClient(victim)
+
+		if victim == previous {
+			previous = nil // its seat was already left out
+		} else {
+			occupied--
+		}
+	}
+
+	if previous != nil {
+		h.removeClient(previous, farewell{})
+	}
+
+	h.addClient(c)
+
+	return nil
+}
+
+// checkQuotas leaves out the streams of the connection c replaces, if any.
+func (h *SSEHub) checkQuotas(c *sseClient, replaced *sseClient) error {
+	ipStreams := h.ipStreams[c.ip]
+	if replaced != nil && replaced.ip == c.ip {
+		ipStreams--
+	}
+
+	if h.quotas.PerIP > 0 && ipStreams >= h.quotas.PerIP {
+		return &QuotaError{Scope: "ip", Key: c.ip, Limit: h.quotas.PerIP}
+	}
+
+	if c.principal == "" {
+		return nil
+	}
+
+	limit := h.quotas.PerPrincipal
+	if principalLimit, ok := h.quotas.PrincipalLimits[c.principal]; ok {
+		limit = principalLimit
+	}
+
+	principalStreams := h.principalStreams[c.principal]
+	if replaced != nil && replaced.principal == c.principal {
+		principalStreams--
+	}
+
+	if limit > 0 && principalStreams >= limit {
+		return &QuotaError{Scope: "principal", Key: c.principal, Limit: limit}
+	}
+
+	return nil
+}
+
+func (h *SSEHub) addClient(c *sseClient) {
+	h.clients[c] = struct{}{}
+	h.byID[c.id] = c
+	h.order = append(h.order, c)
+	h.ipStreams[c.ip]++
+	if c.principal != "" {
+		h.principalStreams[c.principal]++
+	}
+
+	for _, group := range c.groupList() {
+		h.indexMember(group, c)
+	}
+
+	c.shard = h.shards[h.nextShard]
+	h.nextShard = (h.nextShard + 1) % len(h.shards)
+	c.shard.inbox <- shardMessage{add: c}
+	c.registeredSeq = h.seq // every later event reaches the client live
+
+	h.announce(c, EventTypeClientConnected)
+}
+
+func (h *SSEHub) removeClient(c *sseClient, f farewell) {
+	if _, ok := h.clients[c]; !ok {
+		return
+	}
+
+	delete(h.clients, c)
+	delete(h.byID, c.id)
+
+	if h.ipStreams[c.ip]--; h.ipStreams[c.ip] <= 0 {
+		delete(h.ipStreams, c.ip)
+	}
+	if c.principal != "" {
+		if h.principalStreams[c.principal]--; h.principalStreams[c.principal] <= 0 {
+			delete(h.principalStreams, c.principal)
+		}
+	}
+
+	for _, group := range c.groupList() {
+		h.unindexMember(group, c)
+	}
+
+	c.shard.inbox <- shardMessage{remove: c}
+	c.disconnect(f)
+
+	for i, v := range h.order {
+		if v == c {
+			h.order = append(h.order[:i], h.order[i+1:]...)
+			break
+		}
+	}
+
+	h.announce(c, EventTypeClientDisconnected)
+}
+
+func (h *SSEHub) indexMember(group string, c *sseClient) {
+	if h.groups[group] == nil {
+		h.groups[group] = make(map[*sseClient]struct{})
+	}
+	h.groups[group][c] = struct{}{}
+}
+
+func (h *SSEHub) unindexMember(group string, c *sseClient) {
+	delete(h.groups[group], c)
+	if len(h.groups[group]) == 0 {
+		delete(h.groups, group)
+	}
+}
+
+// evictClient removes the client with a jittered retry hint, spreading out reconnections.
+func (h *SSEHub) evictClient(c *sseClient) {
+	h.removeClient(c, farewell{retry: h.jitteredRetry()})
+}
+
+func (h *SSEHub) jitteredRetry() time.Duration {
+	retry := h.evictionRetry
+	if h.evictionRetryJitter > 0 {
+		retry += rand.N(h.evictionRetryJitter)
+	}
+
+	return retry
+}
+
+// DroppedEvents is the number of events that couldn't be delivered to slow clients.
+func (h *SSEHub) DroppedEvents() uint64 {
+	return h.droppedEvents.Load()
+}
+
+// lastSeq returns the sequence number of the last published event.
+func (h *SSEHub) lastSeq() (uint64, error) {
+	var seq uint64
+
+	err := h.do(func() {
+		seq = h.seq
+	})
+
+	return seq, err
+}
+
 func (h *SSEHub) GetEventsAfterID(id string) []Event {
+	if h.eventStore == nil {
+		return nil
+	}
+
 	return h.eventStore.GetEventsAfterID(id)
 }
+
+func (h *SSEHub) QueryEvents(query EventQuery) []Event {
+	if h.eventStore == nil {
+		return nil
+	}
+
+	return h.eventStore.QueryEvents(query)
+}